@@ -0,0 +1,25 @@
+package config
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+const defaultAttendanceEditWindow = 48 * time.Hour
+
+// AttendanceEditWindow is how long after a session ends its faculty may still
+// mark or correct attendance, set by APP_ATTENDANCE_EDIT_WINDOW as a duration
+// such as "48h". Zero means no limit.
+func AttendanceEditWindow() time.Duration {
+	value := os.Getenv("APP_ATTENDANCE_EDIT_WINDOW")
+	if value == "" {
+		return defaultAttendanceEditWindow
+	}
+	window, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("invalid APP_ATTENDANCE_EDIT_WINDOW %q, using %s", value, defaultAttendanceEditWindow)
+		return defaultAttendanceEditWindow
+	}
+	return window
+}
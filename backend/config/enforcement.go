@@ -0,0 +1,20 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// Strict reports whether the scheduling rule configured by the given
+// environment variable rejects writes ("error") rather than only warning
+// about them ("warn", the default).
+func Strict(key string) bool {
+	return strings.EqualFold(os.Getenv(key), "error")
+}
+
+// ChangeApprovalRequired reports whether lecture changes must be approved by
+// a reviewer through a change request (APP_CHANGE_APPROVAL=required) rather
+// than written directly by admins.
+func ChangeApprovalRequired() bool {
+	return strings.EqualFold(os.Getenv("APP_CHANGE_APPROVAL"), "required")
+}
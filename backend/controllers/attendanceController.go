@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+	"tms-server/config"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var attendanceStatuses = []string{"present", "absent", "late", "excused"}
+
+// sessionStudents returns the roll of a lecture: the students of every batch
+// and group attending it, narrowed down to those enrolled for an elective.
+// The lecture's Batches and Subject must be loaded.
+func sessionStudents(db *gorm.DB, l models.Lecture) ([]models.Student, error) {
+	ids := lectureBatchIDs(l)
+	query := db.Where("batch_id IN ? OR group_id IN ?", ids, ids).Order("roll_number")
+	if l.Subject.ElectiveGroupID != nil {
+		query = query.Where("id IN (SELECT student_id FROM student_electives WHERE subject_id = ?)", l.SubjectID)
+	}
+
+	students := []models.Student{}
+	err := query.Find(&students).Error
+	return students, err
+}
+
+// onRoll reports whether a student belongs on a lecture's roll, the same way
+// sessionStudents selects them. The lecture's Batches and Subject and the
+// student's Electives must be loaded.
+func onRoll(st models.Student, l models.Lecture) bool {
+	ids := lectureBatchIDs(l)
+	if !slices.Contains(ids, st.BatchID) && (st.GroupID == nil || !slices.Contains(ids, *st.GroupID)) {
+		return false
+	}
+	if l.Subject.ElectiveGroupID == nil {
+		return true
+	}
+	return slices.ContainsFunc(st.Electives, func(s models.Subject) bool { return s.ID == l.SubjectID })
+}
+
+// sessionBounds returns when a session starts and ends in local time. Its
+// Lecture must be loaded.
+func sessionBounds(s models.Session) (start, end time.Time) {
+	from, to, _ := s.Slot()
+	day := time.Date(s.Date.Year(), s.Date.Month(), s.Date.Day(), 0, 0, 0, 0, time.Local)
+	return day.Add(time.Duration(from) * time.Minute), day.Add(time.Duration(to) * time.Minute)
+}
+
+// attendanceEditableUntil returns until when the faculty may edit a session's
+// attendance, or the zero time if there is no limit.
+func attendanceEditableUntil(s models.Session) time.Time {
+	window := config.AttendanceEditWindow()
+	if window <= 0 {
+		return time.Time{}
+	}
+	_, end := sessionBounds(s)
+	return end.Add(window)
+}
+
+// checkAttendanceAccess returns why the current user may not edit a session's
+// attendance, or an empty string if they may. Admins always may, the faculty
+// taking the session from its start until the edit window has passed.
+// Lecture and Substitution must be loaded.
+func checkAttendanceAccess(c *gin.Context, db *gorm.DB, s models.Session) string {
+	if isAdmin(c) {
+		return ""
+	}
+	if !canManageSession(c, db, s) {
+		return "only the faculty taking this session can mark its attendance"
+	}
+
+	now := time.Now()
+	if start, _ := sessionBounds(s); now.Before(start) {
+		return "attendance can't be marked before the session starts"
+	}
+	if until := attendanceEditableUntil(s); !until.IsZero() && now.After(until) {
+		return fmt.Sprintf("attendance could only be edited until %s", until.Format("2006-01-02 15:04"))
+	}
+	return ""
+}
+
+// attendanceRoll lists every student of a session's roll with their recorded
+// attendance, if any.
+func attendanceRoll(db *gorm.DB, s models.Session) ([]gin.H, map[string]int, error) {
+	students, err := sessionStudents(db, s.Lecture)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var records []models.Attendance
+	if err := db.Where("session_id = ?", s.ID).Find(&records).Error; err != nil {
+		return nil, nil, err
+	}
+	byStudent := make(map[uint]models.Attendance, len(records))
+	for _, r := range records {
+		byStudent[r.StudentID] = r
+	}
+
+	roll := []gin.H{}
+	summary := map[string]int{"present": 0, "absent": 0, "late": 0, "excused": 0, "unmarked": 0}
+	for _, st := range students {
+		entry := gin.H{
+			"student_id":  st.ID,
+			"roll_number": st.RollNumber,
+			"name":        st.Name,
+			"status":      "",
+		}
+		if r, ok := byStudent[st.ID]; ok {
+			entry["status"] = r.Status
+			entry["remarks"] = r.Remarks
+			summary[r.Status]++
+		} else {
+			summary["unmarked"]++
+		}
+		roll = append(roll, entry)
+	}
+	return roll, summary, nil
+}
+
+func GetSessionAttendance(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var session models.Session
+		err := db.Preload("Lecture.Batches").Preload("Lecture.Subject").Preload("Substitution").
+			First(&session, c.Param("id")).Error
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+
+		roll, summary, err := attendanceRoll(db, session)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch attendance"})
+			return
+		}
+
+		response := gin.H{
+			"session_id": session.ID,
+			"date":       session.Date.Format(dateLayout),
+			"status":     session.Status,
+			"editable":   checkAttendanceAccess(c, db, session) == "",
+			"summary":    summary,
+			"data":       roll,
+		}
+		if until := attendanceEditableUntil(session); !until.IsZero() {
+			response["editable_until"] = until
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// MarkAttendance records the attendance of a session's roll in one request.
+// Students left out keep what was recorded for them before. Marking
+// attendance marks the session held.
+func MarkAttendance(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Records []struct {
+				StudentID uint   `json:"student_id" binding:"required"`
+				Status    string `json:"status" binding:"required"`
+				Remarks   string `json:"remarks"`
+			} `json:"records" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var session models.Session
+		err := db.Preload("Lecture.Batches").Preload("Lecture.Subject").Preload("Substitution").
+			First(&session, c.Param("id")).Error
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		if session.Archived {
+			c.JSON(http.StatusConflict, gin.H{"error": "session is archived"})
+			return
+		}
+		if session.Status == models.SessionCancelled {
+			c.JSON(http.StatusConflict, gin.H{"error": "session is cancelled"})
+			return
+		}
+		if reason := checkAttendanceAccess(c, db, session); reason != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": reason})
+			return
+		}
+
+		user, err := currentUser(c, db)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		students, err := sessionStudents(db, session.Lecture)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch students"})
+			return
+		}
+		onRoll := make(map[uint]bool, len(students))
+		for _, st := range students {
+			onRoll[st.ID] = true
+		}
+
+		records := make([]models.Attendance, 0, len(input.Records))
+		seen := make(map[uint]bool, len(input.Records))
+		for i, r := range input.Records {
+			if !onRoll[r.StudentID] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("record %d: student %d is not on this session's roll", i, r.StudentID)})
+				return
+			}
+			if seen[r.StudentID] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("record %d: student %d is listed twice", i, r.StudentID)})
+				return
+			}
+			if !slices.Contains(attendanceStatuses, r.Status) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("record %d: invalid status %q", i, r.Status), "allowed": attendanceStatuses})
+				return
+			}
+			seen[r.StudentID] = true
+			records = append(records, models.Attendance{
+				SessionID:  session.ID,
+				StudentID:  r.StudentID,
+				Status:     r.Status,
+				Remarks:    r.Remarks,
+				MarkedByID: &user.ID,
+			})
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if len(records) > 0 {
+				err := tx.Omit(clause.Associations).Clauses(clause.OnConflict{
+					Columns:   []clause.Column{{Name: "session_id"}, {Name: "student_id"}},
+					DoUpdates: clause.AssignmentColumns([]string{"status", "remarks", "marked_by_id", "updated_at"}),
+				}).Create(&records).Error
+				if err != nil {
+					return err
+				}
+			}
+			if session.Status == models.SessionHeld {
+				return nil
+			}
+			return tx.Model(&session).Updates(statusUpdate(models.SessionHeld, "", "", user)).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		roll, summary, err := attendanceRoll(db, session)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch attendance"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"session_id": session.ID, "summary": summary, "data": roll})
+	}
+}
@@ -3,104 +3,201 @@ package controllers
 import (
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"slices"
 	"strconv"
 	"time"
 	"tms-server/config"
 	"tms-server/models"
 )
 
-func GetCalendarSummaryByMonth(c *gin.Context) {
-	month := c.Query("month")
-	year := c.Query("year")
-	semester := c.Query("semester")
-	facultyID := c.Query("faculty_id")
-	courseID := c.Query("course_id")
+// maxCalendarDays caps the range a calendar summary covers.
+const maxCalendarDays = 731
+
+// calendarFilters are the optional filters of the calendar summary, as for
+// QueryLectures. faculty_id matches whoever takes the session, room_id the
+// room it is actually held in.
+var calendarFilters = []queryFilter{
+	{param: "semester", parse: parseInt, apply: whereIn("lectures.semester")},
+	idFilter("faculty_id", effectiveFacultySQL),
+	attendingBatchFilter("course_id", "course_id", parseID),
+	batchFilter,
+	idFilter("room_id", sessionRoomSQL),
+	idFilter("subject_id", "lectures.subject_id"),
+	{param: "archived", parse: parseBool, single: true, apply: where("sessions.archived = ?")},
+}
 
+// calendarRange reads the summary's date range: from/to, or a whole month
+// given by month and year. It writes a 400 response and returns ok=false
+// when neither is valid.
+func calendarRange(c *gin.Context) (from, to time.Time, ok bool) {
+	if c.Query("from") != "" || c.Query("to") != "" {
+		from, to, ok = parseDateRange(c)
+		if ok && to.Sub(from) > maxCalendarDays*24*time.Hour {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the range must not exceed two years"})
+			return from, to, false
+		}
+		return from, to, ok
+	}
+
+	month, year := c.Query("month"), c.Query("year")
 	if month == "" || year == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Both 'month' and 'year' query parameters are required.",
+			"error": "Either 'from' and 'to' or both 'month' and 'year' query parameters are required.",
 		})
-		return
+		return from, to, false
 	}
-
-	if _, err := strconv.Atoi(month); err != nil {
+	m, err := strconv.Atoi(month)
+	if err != nil || m < 1 || m > 12 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'month' parameter. Must be a number."})
-		return
+		return from, to, false
 	}
-	if _, err := strconv.Atoi(year); err != nil {
+	y, err := strconv.Atoi(year)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'year' parameter. Must be a number."})
-		return
+		return from, to, false
 	}
+	from = time.Date(y, time.Month(m), 1, 0, 0, 0, 0, time.UTC)
+	return from, from.AddDate(0, 1, -1), true
+}
 
-	query := config.DB.Model(&models.Session{}).
-		Joins("JOIN lectures ON lectures.id = sessions.lecture_id").
-		Where("EXTRACT(MONTH FROM sessions.date) = ?", month).
-		Where("EXTRACT(YEAR FROM sessions.date) = ?", year)
+// calendarStat counts the sessions of a day or period.
+type calendarStat struct {
+	Total    int
+	Makeup   int
+	Statuses map[string]int
+}
+
+func newCalendarStat() *calendarStat {
+	stat := &calendarStat{Statuses: make(map[string]int)}
+	for _, status := range models.SessionStatuses {
+		stat.Statuses[statusName(status)] = 0
+	}
+	return stat
+}
 
-	// Optional Filters (faculty_id, course_id, semester)
-	if semester != "" {
-		query = query.Where("lectures.semester = ?", semester)
+// statusName is how a status is reported, "scheduled" for open sessions.
+func statusName(status models.SessionStatus) string {
+	if status == models.SessionScheduled {
+		return "scheduled"
 	}
-	if facultyID != "" {
-		query = query.Where("lectures.faculty_id = ?", facultyID)
+	return string(status)
+}
+
+func (stat *calendarStat) add(s models.Session) {
+	stat.Total++
+	stat.Statuses[statusName(s.Status)]++
+	if s.MakeupForID != nil {
+		stat.Makeup++
 	}
-	if courseID != "" {
-		query = query.
-			Joins("JOIN batches ON batches.id = lectures.batch_id").
-			Where("batches.course_id = ?", courseID)
+}
+
+func (stat *calendarStat) json() gin.H {
+	return gin.H{
+		"total":           stat.Total,
+		"total_held":      stat.Statuses[statusName(models.SessionHeld)],
+		"total_cancelled": stat.Statuses[statusName(models.SessionCancelled)],
+		"no_data":         stat.Statuses[statusName(models.SessionScheduled)],
+		"total_makeup":    stat.Makeup,
+		"statuses":        stat.Statuses,
 	}
+}
 
-	var sessions []models.Session
-	err := query.Preload("Lecture").Find(&sessions).Error
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetching sessions"})
+// GetCalendarSummaryByMonth counts sessions by status for every date of a
+// range, in order and including days without sessions, or per week, month or
+// semester with group_by.
+func GetCalendarSummaryByMonth(c *gin.Context) {
+	from, to, ok := calendarRange(c)
+	if !ok {
+		return
+	}
+	groupBy := c.DefaultQuery("group_by", "day")
+	if !slices.Contains([]string{"day", "week", "month", "semester"}, groupBy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'group_by' must be day, week, month or semester"})
 		return
 	}
 
-	if len(sessions) == 0 {
-		c.JSON(http.StatusOK, gin.H{"message": "no sessions found", "data": []gin.H{}})
+	query := config.DB.Model(&models.Session{}).
+		Joins("JOIN lectures ON lectures.id = sessions.lecture_id").
+		Joins("LEFT JOIN substitutions ON substitutions.session_id = sessions.id").
+		Where("sessions.date BETWEEN ? AND ?", from, to)
+
+	query, ok = applyFilters(c, config.DB, query, calendarFilters)
+	if !ok {
 		return
 	}
 
-	type DayStat struct {
-		Held      int
-		Cancelled int
-		Nil       int
+	var sessions []models.Session
+	if err := query.Select("sessions.*").Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sessions"})
+		return
 	}
-	summary := make(map[string]*DayStat)
+
+	total := newCalendarStat()
+	byDay := make(map[string]*calendarStat)
 	for _, s := range sessions {
-		key := s.Date.Format("2006-01-02")
-		if summary[key] == nil {
-			summary[key] = &DayStat{}
-		}
-		if s.Status == "held" {
-			summary[key].Held++
-		} else if s.Status == "cancelled" {
-			summary[key].Cancelled++
-		} else if s.Status == "" {
-			summary[key].Nil++
+		key := s.Date.Format(dateLayout)
+		if byDay[key] == nil {
+			byDay[key] = newCalendarStat()
 		}
+		byDay[key].add(s)
+		total.add(s)
 	}
 
 	result := []gin.H{}
-	for dateStr, stat := range summary {
-		result = append(result, gin.H{
-			"date":            dateStr,
-			"total_held":      stat.Held,
-			"total_cancelled": stat.Cancelled,
-			"no_data":         stat.Nil,
-		})
+	if groupBy == "day" {
+		for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+			stat := byDay[d.Format(dateLayout)]
+			if stat == nil {
+				stat = newCalendarStat()
+			}
+			entry := stat.json()
+			entry["date"] = d.Format(dateLayout)
+			entry["day_of_week"] = d.Weekday().String()
+			result = append(result, entry)
+		}
+	} else {
+		var current gin.H
+		var stat *calendarStat
+		for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+			period := periodKey(d, groupBy)
+			if current == nil || current["period"] != period {
+				if current != nil {
+					result = append(result, mergeStat(current, stat))
+				}
+				current = gin.H{"period": period, "from": d.Format(dateLayout)}
+				stat = newCalendarStat()
+			}
+			current["to"] = d.Format(dateLayout)
+			if day := byDay[d.Format(dateLayout)]; day != nil {
+				stat.Total += day.Total
+				stat.Makeup += day.Makeup
+				for status, n := range day.Statuses {
+					stat.Statuses[status] += n
+				}
+			}
+		}
+		result = append(result, mergeStat(current, stat))
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": result})
+	c.JSON(http.StatusOK, gin.H{
+		"from":     from.Format(dateLayout),
+		"to":       to.Format(dateLayout),
+		"group_by": groupBy,
+		"total":    total.json(),
+		"data":     result,
+	})
+}
+
+// mergeStat adds a period's counts to its entry.
+func mergeStat(entry gin.H, stat *calendarStat) gin.H {
+	for k, v := range stat.json() {
+		entry[k] = v
+	}
+	return entry
 }
 
 func GetLectureDetailsByDate(c *gin.Context) {
 	dateStr := c.Query("date")
-	semester := c.Query("semester")
-	facultyID := c.Query("faculty_id")
-	courseID := c.Query("course_id")
-
 	if dateStr == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "date is required in YYYY-MM-DD format"})
 		return
@@ -112,8 +209,25 @@ func GetLectureDetailsByDate(c *gin.Context) {
 		return
 	}
 
+	// Filtered as the calendar summary is, so that both agree on a day.
+	query := config.DB.
+		Preload("Substitution.SubstituteFaculty").
+		Preload("Room").
+		Preload("Lecture.Subject").
+		Preload("Lecture.Faculty").
+		Preload("Lecture.Room").
+		Preload("Lecture.Batch.Course").
+		Preload("Lecture.Batches").
+		Joins("JOIN lectures ON lectures.id = sessions.lecture_id").
+		Joins("LEFT JOIN substitutions ON substitutions.session_id = sessions.id").
+		Where("sessions.date = ?", date)
+	query, ok := applyFilters(c, config.DB, query, calendarFilters)
+	if !ok {
+		return
+	}
+
 	var sessions []models.Session
-	if err := config.DB.Where("date = ?", date).Find(&sessions).Error; err != nil {
+	if err := query.Select("sessions.*").Find(&sessions).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sessions"})
 		return
 	}
@@ -123,67 +237,75 @@ func GetLectureDetailsByDate(c *gin.Context) {
 		return
 	}
 
-	lectureIDs := make([]uint, 0, len(sessions))
+	sessionIDs := make([]uint, 0, len(sessions))
 	for _, s := range sessions {
-		lectureIDs = append(lectureIDs, s.LectureID)
+		sessionIDs = append(sessionIDs, s.ID)
 	}
 
-	lectureQuery := config.DB.
-		Preload("Subject").
-		Preload("Faculty").
-		Preload("Room").
-		Preload("Batch.Course").
-		Where("lectures.id IN ?", lectureIDs)
-
-	// Optional Filters (faculty_id, course_id, semester)
-	if semester != "" {
-		lectureQuery = lectureQuery.Where("lectures.semester = ?", semester)
-	}
-	if facultyID != "" {
-		lectureQuery = lectureQuery.Where("lectures.faculty_id = ?", facultyID)
-	}
-	if courseID != "" {
-		lectureQuery = lectureQuery.
-			Joins("JOIN batches ON batches.id = lectures.batch_id").
-			Where("batches.course_id = ?", courseID)
-	}
-
-	var lectures []models.Lecture
-	if err := lectureQuery.Find(&lectures).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch lecture details"})
+	var makeups []models.Session
+	if err := config.DB.Where("makeup_for_id IN ?", sessionIDs).Find(&makeups).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch make-up sessions"})
 		return
 	}
-
-	if len(lectures) == 0 {
-		c.JSON(http.StatusOK, gin.H{"message": "no lectures found", "data": []gin.H{}})
-		return
+	makeupFor := make(map[uint]models.Session, len(makeups))
+	for _, m := range makeups {
+		makeupFor[*m.MakeupForID] = m
 	}
 
-	lectureMap := make(map[uint]models.Lecture)
-	for _, l := range lectures {
-		lectureMap[l.ID] = l
+	leaves, err := approvedLeaves(config.DB, date, date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch leaves"})
+		return
 	}
 
 	result := []gin.H{}
 	for _, s := range sessions {
-		lecture, exists := lectureMap[s.LectureID]
-		if !exists {
-			continue
+		lecture := s.Lecture
+		startTime, endTime, _ := s.Slot()
+		room := lecture.Room.Name
+		if s.Room != nil {
+			room = s.Room.Name
 		}
-		result = append(result, gin.H{
+		entry := gin.H{
 			"lecture_id":    s.LectureID,
 			"subject":       lecture.Subject.Name,
 			"faculty":       lecture.Faculty.Name,
-			"start_time":    lecture.StartTime,
-			"end_time":      lecture.EndTime,
+			"start_time":    startTime,
+			"end_time":      endTime,
 			"status":        s.Status,
 			"semester":      lecture.Semester,
-			"room":          lecture.Room.Name,
+			"room":          room,
 			"batch_year":    lecture.Batch.Year,
 			"batch_section": lecture.Batch.Section,
 			"course_name":   lecture.Batch.Course.Name,
-			"session_id":   s.ID,
-		})
+			"batch_ids":     lectureBatchIDs(lecture),
+			"session_id":    s.ID,
+		}
+		if s.CancelReason != "" {
+			entry["cancel_reason"] = s.CancelReason
+		}
+		if s.MakeupForID != nil {
+			entry["makeup_for_session_id"] = *s.MakeupForID
+		}
+		if m, ok := makeupFor[s.ID]; ok {
+			entry["rescheduled_to_session_id"] = m.ID
+			entry["rescheduled_to_date"] = m.Date.Format("2006-01-02")
+		}
+		if s.Substitution != nil {
+			entry["substitute_faculty_id"] = s.Substitution.SubstituteFacultyID
+			entry["substitute_faculty"] = s.Substitution.SubstituteFaculty.Name
+		} else if leave := leaveOn(leaves[lecture.FacultyID], date); leave != nil {
+			entry["leave_type"] = leave.Type
+			if s.Status == models.SessionScheduled || slices.Contains(leaveFlags, s.Status) {
+				flag, err := leaveFlag(config.DB, s)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check substitutes"})
+					return
+				}
+				entry["flag"] = flag
+			}
+		}
+		result = append(result, entry)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
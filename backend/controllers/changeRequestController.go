@@ -0,0 +1,394 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errLectureGone aborts applying a change to a lecture that was removed or
+// archived since the change was requested.
+var errLectureGone = errors.New("the lecture is no longer on the timetable")
+
+// errAlreadyReviewed aborts a review that lost the race to another one.
+var errAlreadyReviewed = errors.New("the change request was already reviewed")
+
+// changeRequestFilters are the query parameters QueryChangeRequests
+// understands. batch_id also matches the batch's parent and groups.
+var changeRequestFilters = []queryFilter{
+	{
+		param: "batch_id",
+		parse: parseID,
+		apply: func(db, query *gorm.DB, values []any) (*gorm.DB, error) {
+			ids := make([]uint, len(values))
+			for i, v := range values {
+				ids[i] = v.(uint)
+			}
+			related, err := relatedBatchIDs(db, ids)
+			if err != nil {
+				return nil, err
+			}
+			return query.Where("batch_id IN ?", related), nil
+		},
+	},
+	idFilter("lecture_id", "lecture_id"),
+	{param: "kind", parse: parseText, apply: whereIn("kind")},
+	{param: "status", parse: parseText, apply: whereIn("status")},
+	{param: "semester", parse: parseInt, apply: whereIn("semester")},
+}
+
+// proposedLecture returns the lecture an addition or move asks for.
+func proposedLecture(r models.ChangeRequest) models.Lecture {
+	l := models.Lecture{
+		DayOfWeek: r.DayOfWeek,
+		StartTime: r.StartTime,
+		EndTime:   r.EndTime,
+		SubjectID: r.SubjectID,
+		FacultyID: r.FacultyID,
+		BatchID:   r.BatchID,
+		Semester:  r.Semester,
+		RoomID:    r.RoomID,
+		Batches:   r.Batches,
+	}
+	if r.Kind == "move" && r.LectureID != nil {
+		l.ID = *r.LectureID
+	}
+	return l
+}
+
+// lectureChange returns a change request for a lecture as it is.
+func lectureChange(l models.Lecture) models.ChangeRequest {
+	id := l.ID
+	return models.ChangeRequest{
+		LectureID: &id,
+		DayOfWeek: l.DayOfWeek,
+		StartTime: l.StartTime,
+		EndTime:   l.EndTime,
+		SubjectID: l.SubjectID,
+		FacultyID: l.FacultyID,
+		BatchID:   l.BatchID,
+		Semester:  l.Semester,
+		RoomID:    l.RoomID,
+		Batches:   l.Batches,
+	}
+}
+
+// writeChangeRequest saves a change request together with the combined
+// batches of the lecture it proposes.
+func writeChangeRequest(tx *gorm.DB, r *models.ChangeRequest) error {
+	if err := tx.Omit(clause.Associations).Save(r).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("change_request_id = ?", r.ID).Delete(&models.ChangeRequestBatch{}).Error; err != nil {
+		return err
+	}
+
+	rows := []models.ChangeRequestBatch{}
+	for _, id := range lectureBatchIDs(models.Lecture{BatchID: r.BatchID, Batches: r.Batches}) {
+		if id != r.BatchID {
+			rows = append(rows, models.ChangeRequestBatch{ChangeRequestID: r.ID, BatchID: id})
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.Create(&rows).Error
+}
+
+// liveLecture loads a lecture a change applies to, failing with
+// errLectureGone if it left the timetable.
+func liveLecture(db *gorm.DB, id uint) (models.Lecture, error) {
+	var l models.Lecture
+	err := db.Preload("Batches").First(&l, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && l.Archived) {
+		return l, errLectureGone
+	}
+	return l, err
+}
+
+// QueryChangeRequests lists change requests matching the
+// changeRequestFilters, newest first, e.g. the history of a batch's timetable
+// with batch_id.
+func QueryChangeRequests(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query, ok := applyFilters(c, db, db.Preload("Batches").Order("id DESC"), changeRequestFilters)
+		if !ok {
+			return
+		}
+
+		requests := []models.ChangeRequest{}
+		if err := query.Find(&requests).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, requests)
+	}
+}
+
+func GetChangeRequest(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.ChangeRequest
+		if err := db.Preload("Batch").Preload("Batches").First(&request, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		c.JSON(http.StatusOK, request)
+	}
+}
+
+// CreateChangeRequest proposes a lecture change. Additions carry the new
+// lecture and moves the fields to change; both are checked against the
+// scheduling rules right away. A lecture can only have one pending move or
+// removal.
+func CreateChangeRequest(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.ChangeRequest
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		user, err := currentUser(c, db)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		switch request.Kind {
+		case "add":
+			request.LectureID = nil
+		case "move", "remove":
+			if request.LectureID == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "LectureID is required to move or remove a lecture"})
+				return
+			}
+			lecture, err := liveLecture(db, *request.LectureID)
+			if errors.Is(err, errLectureGone) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			var pending int64
+			err = db.Model(&models.ChangeRequest{}).
+				Where("lecture_id = ? AND status = ?", lecture.ID, "pending").
+				Count(&pending).Error
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if pending > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "the lecture already has a pending change request"})
+				return
+			}
+
+			// A removal records the lecture as it is; a move changes the
+			// fields it gives and keeps the others.
+			kind := request.Kind
+			request = lectureChange(lecture)
+			request.Kind = kind
+			if kind == "move" {
+				if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+				request.LectureID = &lecture.ID
+			}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Kind must be add, move or remove"})
+			return
+		}
+
+		request.ID = 0
+		request.Status = "pending"
+		request.RequestedByID = user.ID
+		request.ReviewedByID, request.ReviewedAt, request.ReviewComment = nil, nil, ""
+		request.PreviousDayOfWeek, request.PreviousStartTime, request.PreviousEndTime, request.PreviousRoomID = "", nil, nil, nil
+
+		warnings := []lectureIssue{}
+		if request.Kind != "remove" {
+			l := proposedLecture(request)
+			var blocking []lectureIssue
+			blocking, warnings, err = validateLecture(db, &l)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to validate lecture"})
+				return
+			}
+			if len(blocking) > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "lecture violates scheduling rules", "issues": blocking})
+				return
+			}
+			request.Batches = l.Batches
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			return writeChangeRequest(tx, &request)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"data": request, "warnings": warnings})
+	}
+}
+
+// applyChangeRequest writes an approved change to the lectures. The lecture
+// is validated again against the timetable as it is now. It returns the
+// warnings and the number of open sessions dropped.
+func applyChangeRequest(tx *gorm.DB, r *models.ChangeRequest) (blocking, warnings []lectureIssue, dropped int64, err error) {
+	warnings = []lectureIssue{}
+
+	if r.Kind == "remove" {
+		if _, err := liveLecture(tx, *r.LectureID); err != nil {
+			return nil, nil, 0, err
+		}
+		dropped, err = retireLecture(tx, *r.LectureID)
+		return nil, warnings, dropped, err
+	}
+
+	l := proposedLecture(*r)
+	if r.Kind == "move" {
+		old, err := liveLecture(tx, *r.LectureID)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		r.PreviousDayOfWeek = old.DayOfWeek
+		r.PreviousStartTime, r.PreviousEndTime, r.PreviousRoomID = &old.StartTime, &old.EndTime, &old.RoomID
+		if old.DayOfWeek != l.DayOfWeek {
+			if dropped, err = dropOpenSessions(tx, old.ID); err != nil {
+				return nil, nil, 0, err
+			}
+		}
+	}
+
+	blocking, warnings, err = validateLecture(tx, &l)
+	if err != nil || len(blocking) > 0 {
+		return blocking, warnings, 0, err
+	}
+	if err := writeLecture(tx, &l); err != nil {
+		return nil, nil, 0, err
+	}
+	r.LectureID = &l.ID
+	return nil, warnings, dropped, nil
+}
+
+// ReviewChangeRequest approves or rejects a pending change request with the
+// reviewer's comment, which rejections must give. Approving applies the
+// change; if it no longer fits the timetable the request stays pending and
+// the blocking issues are returned.
+func ReviewChangeRequest(db *gorm.DB, status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.ChangeRequest
+		if err := db.Preload("Batches").First(&request, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		if request.Status != "pending" {
+			c.JSON(http.StatusConflict, gin.H{"error": "the change request was already " + request.Status})
+			return
+		}
+
+		var input struct {
+			Comment string `json:"comment"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if status == "rejected" && input.Comment == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a comment is required to reject a change request"})
+			return
+		}
+
+		user, err := currentUser(c, db)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		var blocking, warnings []lectureIssue
+		var dropped int64
+		err = db.Transaction(func(tx *gorm.DB) error {
+			// Claim the request first; a concurrent review waits on the row
+			// and then finds it no longer pending.
+			res := tx.Model(&models.ChangeRequest{}).
+				Where("id = ? AND status = ?", request.ID, "pending").
+				Update("status", status)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return errAlreadyReviewed
+			}
+
+			if status == "approved" {
+				var err error
+				blocking, warnings, dropped, err = applyChangeRequest(tx, &request)
+				if err != nil {
+					return err
+				}
+				if len(blocking) > 0 {
+					return errLectureRejected
+				}
+			}
+
+			now := time.Now()
+			request.Status = status
+			request.ReviewedByID = &user.ID
+			request.ReviewedAt = &now
+			request.ReviewComment = input.Comment
+			return tx.Omit(clause.Associations).Save(&request).Error
+		})
+		if len(blocking) > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "lecture violates scheduling rules", "issues": blocking})
+			return
+		}
+		if errors.Is(err, errLectureGone) || errors.Is(err, errAlreadyReviewed) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": request, "warnings": warnings, "dropped_sessions": dropped})
+	}
+}
+
+// DeleteChangeRequest withdraws a pending change request. Reviewed requests
+// are kept as history.
+func DeleteChangeRequest(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.ChangeRequest
+		if err := db.First(&request, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		if request.Status != "pending" {
+			c.JSON(http.StatusConflict, gin.H{"error": "only pending change requests can be withdrawn"})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("change_request_id = ?", request.ID).Delete(&models.ChangeRequestBatch{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&request).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+	}
+}
@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"tms-server/models"
+	"tms-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// checkinOpensBefore is how long before a session starts students may
+	// check in.
+	checkinOpensBefore = 10 * time.Minute
+	// checkinLateAfter is how long after a session starts a check-in still
+	// counts as present rather than late.
+	checkinLateAfter = 10 * time.Minute
+)
+
+// checkinOpen reports whether students may check in to a session at now:
+// from shortly before it starts until it ends. Its Lecture must be loaded.
+func checkinOpen(s models.Session, now time.Time) bool {
+	start, end := sessionBounds(s)
+	return !now.Before(start.Add(-checkinOpensBefore)) && !now.After(end)
+}
+
+// GetCheckinToken issues the current rotating check-in token of a session for
+// its faculty to display as a QR code. Clients fetch a new one when it
+// expires.
+func GetCheckinToken(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var session models.Session
+		if err := db.Preload("Lecture").Preload("Substitution").First(&session, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		if !canManageSession(c, db, session) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only the faculty taking this session can open check-in"})
+			return
+		}
+		if session.Archived {
+			c.JSON(http.StatusConflict, gin.H{"error": "session is archived"})
+			return
+		}
+		if session.Status == models.SessionCancelled {
+			c.JSON(http.StatusConflict, gin.H{"error": "session is cancelled"})
+			return
+		}
+
+		now := time.Now()
+		if !checkinOpen(session, now) {
+			c.JSON(http.StatusConflict, gin.H{"error": "check-in is only open from shortly before the session starts until it ends"})
+			return
+		}
+
+		token, expires, err := utils.GenerateCheckinToken(session.ID, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"session_id": session.ID,
+			"token":      token,
+			"qr_payload": "tms-checkin:" + token,
+			"expires_at": expires,
+		})
+	}
+}
+
+// CheckIn records the current student as present in the session of a scanned
+// check-in token, or late if the session started a while ago. A check-in
+// never overwrites attendance that was already recorded.
+func CheckIn(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Token string `json:"token" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		now := time.Now()
+		sessionID, err := utils.ValidateCheckinToken(input.Token, now)
+		if errors.Is(err, utils.ErrNoCheckinSecret) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var session models.Session
+		if err := db.Preload("Lecture.Batches").Preload("Lecture.Subject").First(&session, sessionID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		if session.Archived {
+			c.JSON(http.StatusConflict, gin.H{"error": "session is archived"})
+			return
+		}
+		if session.Status == models.SessionCancelled {
+			c.JSON(http.StatusConflict, gin.H{"error": "session is cancelled"})
+			return
+		}
+		if !checkinOpen(session, now) {
+			c.JSON(http.StatusConflict, gin.H{"error": "check-in for this session is closed"})
+			return
+		}
+
+		student, err := currentStudent(c, db)
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "No student profile linked to this user"})
+			return
+		}
+		if !onRoll(*student, session.Lecture) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "you are not enrolled in this session"})
+			return
+		}
+
+		status := "present"
+		if start, _ := sessionBounds(session); now.After(start.Add(checkinLateAfter)) {
+			status = "late"
+		}
+		record := models.Attendance{
+			SessionID:  session.ID,
+			StudentID:  student.ID,
+			Status:     status,
+			Remarks:    "self check-in",
+			MarkedByID: student.UserID,
+		}
+
+		var created bool
+		err = db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+			if result.Error != nil {
+				return result.Error
+			}
+			created = result.RowsAffected > 0
+			if !created || session.Status == models.SessionHeld {
+				return nil
+			}
+			return tx.Model(&session).Updates(statusUpdate(models.SessionHeld, "", "", &models.User{ID: *student.UserID})).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !created {
+			var existing models.Attendance
+			if err := db.Where("session_id = ? AND student_id = ?", session.ID, student.ID).First(&existing).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"message": "attendance already recorded", "session_id": session.ID, "status": existing.Status})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"message": "checked in", "session_id": session.ID, "status": status})
+	}
+}
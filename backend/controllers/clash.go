@@ -0,0 +1,298 @@
+package controllers
+
+import (
+	"errors"
+	"slices"
+	"time"
+	"tms-server/models"
+
+	"gorm.io/gorm"
+)
+
+// SQL fragments resolving a session's actual slot. One-off sessions carry
+// their own times and room, regular ones follow their lecture. Queries using
+// them must join lectures.
+const (
+	oneOffSessionSQL = "sessions.start_time IS NOT NULL"
+	sessionStartSQL  = "COALESCE(sessions.start_time, lectures.start_time)"
+	sessionEndSQL    = "COALESCE(sessions.end_time, lectures.end_time)"
+	sessionRoomSQL   = "COALESCE(sessions.room_id, lectures.room_id)"
+)
+
+// activeLectureSQL leaves archived lectures out of the weekly grid.
+const activeLectureSQL = "NOT lectures.archived"
+
+// sessionRecordsSQL matches sessions with something recorded against them: a
+// substitution, attendance, syllabus topics or a make-up class.
+const sessionRecordsSQL = `EXISTS (SELECT 1 FROM substitutions WHERE substitutions.session_id = sessions.id)
+	OR EXISTS (SELECT 1 FROM attendances WHERE attendances.session_id = sessions.id)
+	OR EXISTS (SELECT 1 FROM session_topics WHERE session_topics.session_id = sessions.id)
+	OR EXISTS (SELECT 1 FROM sessions makeups WHERE makeups.makeup_for_id = sessions.id)`
+
+// lectureHasBatchSQL matches lectures attended by any batch of a set, as
+// their own batch or as one of the batches combined into them. It takes the
+// set twice.
+const lectureHasBatchSQL = `(lectures.batch_id IN ? OR EXISTS (
+	SELECT 1 FROM lecture_batches
+	WHERE lecture_batches.lecture_id = lectures.id AND lecture_batches.batch_id IN ?
+))`
+
+// lectureAttendedBySQL matches a lecture attended by the batch aliased
+// "attending".
+const lectureAttendedBySQL = `(attending.id = lectures.batch_id OR EXISTS (
+	SELECT 1 FROM lecture_batches
+	WHERE lecture_batches.lecture_id = lectures.id AND lecture_batches.batch_id = attending.id
+))`
+
+// lectureBatchIDs returns every batch or group attending a lecture. Its
+// combined Batches must be loaded.
+func lectureBatchIDs(l models.Lecture) []uint {
+	ids := []uint{l.BatchID}
+	for _, b := range l.Batches {
+		if !slices.Contains(ids, b.ID) {
+			ids = append(ids, b.ID)
+		}
+	}
+	return ids
+}
+
+// relatedBatchIDs expands a set of batches with their parent batches and
+// sub-groups: a section can't have a class while one of its groups does, but
+// two groups of a section can.
+func relatedBatchIDs(db *gorm.DB, ids []uint) ([]uint, error) {
+	var related []uint
+	err := db.Model(&models.Batch{}).
+		Where("id IN ? OR parent_id IN ?", ids, ids).
+		Or("id IN (SELECT parent_id FROM batches WHERE id IN ?)", ids).
+		Pluck("id", &related).Error
+	return related, err
+}
+
+// electiveGroupOf returns the elective group of a lecture's subject, or 0.
+// The lecture's Subject must be loaded.
+func electiveGroupOf(l models.Lecture) uint {
+	if l.Subject.ElectiveGroupID == nil {
+		return 0
+	}
+	return *l.Subject.ElectiveGroupID
+}
+
+// slotQuery describes a concrete occurrence on a date to check for clashes.
+// Zero IDs are not checked. BatchIDs must already be expanded with
+// relatedBatchIDs. Lectures of the same ElectiveGroupID run in parallel and
+// don't clash over their batches.
+type slotQuery struct {
+	Date             time.Time
+	StartTime        models.TimeOfDay
+	EndTime          models.TimeOfDay
+	RoomID           uint
+	FacultyID        uint
+	BatchIDs         []uint
+	ElectiveGroupID  uint
+	ExcludeSessionID uint
+}
+
+// conflict is something already booked that overlaps a slotQuery.
+type conflict struct {
+	Kind      string           `json:"kind"` // room, faculty or batch
+	LectureID uint             `json:"lecture_id"`
+	SessionID uint             `json:"session_id,omitempty"`
+	DayOfWeek string           `json:"day_of_week,omitempty"`
+	Date      string           `json:"date,omitempty"`
+	StartTime models.TimeOfDay `json:"start_time"`
+	EndTime   models.TimeOfDay `json:"end_time"`
+}
+
+// findSlotConflicts checks a dated slot against the regular lecture grid and
+// against the sessions on that date that deviate from it (one-off sessions
+// and substitutions). Grid occurrences whose session was cancelled are free.
+func findSlotConflicts(db *gorm.DB, q slotQuery) ([]conflict, error) {
+	conflicts := []conflict{}
+	date := q.Date.Format(dateLayout)
+
+	var lectures []models.Lecture
+	err := db.Preload("Batches").Preload("Subject").
+		Where(activeLectureSQL).
+		Where("lectures.day_of_week = ?", q.Date.Weekday().String()).
+		Where("lectures.start_time < ? AND lectures.end_time > ?", q.EndTime, q.StartTime).
+		Where(`NOT EXISTS (
+			SELECT 1 FROM sessions
+			WHERE sessions.lecture_id = lectures.id AND sessions.date = ?
+			AND NOT (`+oneOffSessionSQL+`) AND sessions.status = 'cancelled'
+		)`, q.Date).
+		Where(db.Where("lectures.room_id = ?", q.RoomID).
+			Or(lectureHasBatchSQL, q.BatchIDs, q.BatchIDs).
+			Or(`lectures.faculty_id = ? AND NOT EXISTS (
+				SELECT 1 FROM sessions
+				JOIN substitutions ON substitutions.session_id = sessions.id
+				WHERE sessions.lecture_id = lectures.id AND sessions.date = ?
+				AND NOT (`+oneOffSessionSQL+`)
+			)`, q.FacultyID, q.Date)).
+		Find(&lectures).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range lectures {
+		for _, kind := range clashKinds(q, l.RoomID, l.FacultyID, lectureBatchIDs(l), electiveGroupOf(l)) {
+			conflicts = append(conflicts, conflict{
+				Kind:      kind,
+				LectureID: l.ID,
+				Date:      date,
+				StartTime: l.StartTime,
+				EndTime:   l.EndTime,
+			})
+		}
+	}
+
+	var sessions []models.Session
+	err = db.Preload("Lecture.Batches").Preload("Lecture.Subject").Preload("Substitution").
+		Joins("JOIN lectures ON lectures.id = sessions.lecture_id").
+		Joins("LEFT JOIN substitutions ON substitutions.session_id = sessions.id").
+		Where("sessions.date = ?", q.Date).
+		Where("sessions.id <> ?", q.ExcludeSessionID).
+		Where("sessions.status IS DISTINCT FROM 'cancelled'").
+		Where("("+oneOffSessionSQL+") OR substitutions.id IS NOT NULL").
+		Where(sessionStartSQL+" < ? AND "+sessionEndSQL+" > ?", q.EndTime, q.StartTime).
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range sessions {
+		start, end, roomID := s.Slot()
+		facultyID := s.Lecture.FacultyID
+		if s.Substitution != nil {
+			facultyID = s.Substitution.SubstituteFacultyID
+		}
+
+		kinds := clashKinds(q, roomID, facultyID, lectureBatchIDs(s.Lecture), electiveGroupOf(s.Lecture))
+		if s.StartTime == nil {
+			// Room and batch of a regular session were already checked
+			// against the grid, only the substitute is new here.
+			kinds = clashKinds(slotQuery{FacultyID: q.FacultyID}, 0, facultyID, nil, 0)
+		}
+		for _, kind := range kinds {
+			conflicts = append(conflicts, conflict{
+				Kind:      kind,
+				LectureID: s.LectureID,
+				SessionID: s.ID,
+				Date:      date,
+				StartTime: start,
+				EndTime:   end,
+			})
+		}
+	}
+
+	return conflicts, nil
+}
+
+// findLectureClashes checks a lecture against the rest of the weekly grid.
+// A lecture spanning several periods is checked as one block, a combined
+// lecture blocks every batch and group attending it. The lecture's combined
+// Batches must be loaded.
+func findLectureClashes(db *gorm.DB, l *models.Lecture) ([]conflict, error) {
+	batchIDs, err := relatedBatchIDs(db, lectureBatchIDs(*l))
+	if err != nil {
+		return nil, err
+	}
+
+	var subject models.Subject
+	if err := db.First(&subject, l.SubjectID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var lectures []models.Lecture
+	err = db.Preload("Batches").Preload("Subject").
+		Where(activeLectureSQL).
+		Where("lectures.id <> ?", l.ID).
+		Where("lectures.day_of_week = ?", l.DayOfWeek).
+		Where("lectures.start_time < ? AND lectures.end_time > ?", l.EndTime, l.StartTime).
+		Where(db.Where("lectures.room_id = ?", l.RoomID).
+			Or("lectures.faculty_id = ?", l.FacultyID).
+			Or(lectureHasBatchSQL, batchIDs, batchIDs)).
+		Find(&lectures).Error
+	if err != nil {
+		return nil, err
+	}
+
+	q := slotQuery{
+		RoomID:          l.RoomID,
+		FacultyID:       l.FacultyID,
+		BatchIDs:        batchIDs,
+		ElectiveGroupID: electiveGroupOf(models.Lecture{Subject: subject}),
+	}
+	conflicts := []conflict{}
+	for _, other := range lectures {
+		for _, kind := range clashKinds(q, other.RoomID, other.FacultyID, lectureBatchIDs(other), electiveGroupOf(other)) {
+			conflicts = append(conflicts, conflict{
+				Kind:      kind,
+				LectureID: other.ID,
+				DayOfWeek: other.DayOfWeek,
+				StartTime: other.StartTime,
+				EndTime:   other.EndTime,
+			})
+		}
+	}
+	return conflicts, nil
+}
+
+func clashKinds(q slotQuery, roomID, facultyID uint, batchIDs []uint, electiveGroupID uint) []string {
+	kinds := []string{}
+	if q.RoomID != 0 && q.RoomID == roomID {
+		kinds = append(kinds, "room")
+	}
+	if q.FacultyID != 0 && q.FacultyID == facultyID {
+		kinds = append(kinds, "faculty")
+	}
+	if q.ElectiveGroupID != 0 && q.ElectiveGroupID == electiveGroupID {
+		return kinds
+	}
+	for _, id := range batchIDs {
+		if slices.Contains(q.BatchIDs, id) {
+			kinds = append(kinds, "batch")
+			break
+		}
+	}
+	return kinds
+}
+
+// busyRoomIDs returns the rooms booked between start and end, either in the
+// weekly grid on day or, when date is set, on that date (taking cancelled and
+// one-off sessions into account). The lecture excludeLectureID is ignored.
+func busyRoomIDs(db *gorm.DB, day string, date *time.Time, start, end models.TimeOfDay, excludeLectureID uint) ([]uint, error) {
+	grid := db.Model(&models.Lecture{}).
+		Where(activeLectureSQL).
+		Where("lectures.id <> ?", excludeLectureID).
+		Where("lectures.day_of_week = ?", day).
+		Where("lectures.start_time < ? AND lectures.end_time > ?", end, start)
+	if date != nil {
+		grid = grid.Where(`NOT EXISTS (
+			SELECT 1 FROM sessions
+			WHERE sessions.lecture_id = lectures.id AND sessions.date = ?
+			AND NOT (`+oneOffSessionSQL+`) AND sessions.status = 'cancelled'
+		)`, *date)
+	}
+
+	var ids []uint
+	if err := grid.Distinct().Pluck("lectures.room_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if date == nil {
+		return ids, nil
+	}
+
+	var oneOff []uint
+	err := db.Table("sessions").
+		Joins("JOIN lectures ON lectures.id = sessions.lecture_id").
+		Where("sessions.date = ?", *date).
+		Where(oneOffSessionSQL).
+		Where("sessions.status IS DISTINCT FROM 'cancelled'").
+		Where(sessionStartSQL+" < ? AND "+sessionEndSQL+" > ?", end, start).
+		Distinct().
+		Pluck(sessionRoomSQL, &oneOff).Error
+	if err != nil {
+		return nil, err
+	}
+	return append(ids, oneOff...), nil
+}
@@ -0,0 +1,248 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// electiveStudents returns, for the batches that recorded their choices in
+// the elective group of a subject, how many of their students chose it.
+// Students enrolled in the group's electives take precedence over recorded
+// counts. It is empty for subjects outside an elective group.
+func electiveStudents(db *gorm.DB, subjectID uint, batchIDs []uint) (map[uint]int, error) {
+	chosen := make(map[uint]int)
+
+	var subject models.Subject
+	if err := db.First(&subject, subjectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return chosen, nil
+		}
+		return nil, err
+	}
+	if subject.ElectiveGroupID == nil {
+		return chosen, nil
+	}
+
+	var choices []models.ElectiveChoice
+	err := db.Joins("JOIN subjects ON subjects.id = elective_choices.subject_id").
+		Where("subjects.elective_group_id = ?", *subject.ElectiveGroupID).
+		Where("elective_choices.batch_id IN ?", batchIDs).
+		Find(&choices).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ch := range choices {
+		if ch.SubjectID == subjectID {
+			chosen[ch.BatchID] += ch.Students
+		} else if _, ok := chosen[ch.BatchID]; !ok {
+			chosen[ch.BatchID] = 0
+		}
+	}
+
+	var enrollments []struct {
+		BatchID   uint
+		GroupID   *uint
+		SubjectID uint
+	}
+	err = db.Table("student_electives").
+		Select("students.batch_id, students.group_id, student_electives.subject_id").
+		Joins("JOIN students ON students.id = student_electives.student_id").
+		Joins("JOIN subjects ON subjects.id = student_electives.subject_id").
+		Where("subjects.elective_group_id = ?", *subject.ElectiveGroupID).
+		Where("students.batch_id IN ? OR students.group_id IN ?", batchIDs, batchIDs).
+		Scan(&enrollments).Error
+	if err != nil {
+		return nil, err
+	}
+
+	enrolled := make(map[uint]int)
+	for _, e := range enrollments {
+		for _, id := range batchIDs {
+			if id != e.BatchID && (e.GroupID == nil || id != *e.GroupID) {
+				continue
+			}
+			if _, ok := enrolled[id]; !ok {
+				enrolled[id] = 0
+			}
+			if e.SubjectID == subjectID {
+				enrolled[id]++
+			}
+		}
+	}
+	for id, n := range enrolled {
+		chosen[id] = n
+	}
+	return chosen, nil
+}
+
+func QueryElectiveGroups(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Preload("Subjects").Order("semester, name")
+
+		if courseID := c.Query("course_id"); courseID != "" {
+			query = query.Where("course_id = ?", courseID)
+		}
+		if semester := c.Query("semester"); semester != "" {
+			query = query.Where("semester = ?", semester)
+		}
+
+		groups := []models.ElectiveGroup{}
+		if err := query.Find(&groups).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, groups)
+	}
+}
+
+// GetElectiveChoices lists how many students of each batch chose each subject
+// of an elective group, with the totals per subject.
+func GetElectiveChoices(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var group models.ElectiveGroup
+		if err := db.Preload("Subjects").First(&group, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+
+		query := db.Preload("Batch").
+			Joins("JOIN subjects ON subjects.id = elective_choices.subject_id").
+			Where("subjects.elective_group_id = ?", group.ID).
+			Order("elective_choices.batch_id, elective_choices.subject_id")
+		if batchID := c.Query("batch_id"); batchID != "" {
+			query = query.Where("elective_choices.batch_id = ?", batchID)
+		}
+
+		choices := []models.ElectiveChoice{}
+		if err := query.Find(&choices).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		totals := make(map[uint]int, len(group.Subjects))
+		for _, ch := range choices {
+			totals[ch.SubjectID] += ch.Students
+		}
+
+		var enrolled []struct {
+			SubjectID uint
+			Students  int
+		}
+		err := db.Table("student_electives").
+			Select("subject_id, COUNT(*) AS students").
+			Where("subject_id IN (SELECT id FROM subjects WHERE elective_group_id = ?)", group.ID).
+			Group("subject_id").
+			Scan(&enrolled).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		enrolledBySubject := make(map[uint]int, len(enrolled))
+		for _, e := range enrolled {
+			enrolledBySubject[e.SubjectID] = e.Students
+		}
+
+		subjects := []gin.H{}
+		for _, s := range group.Subjects {
+			subjects = append(subjects, gin.H{
+				"subject_id": s.ID,
+				"subject":    s.Name,
+				"students":   totals[s.ID],
+				"enrolled":   enrolledBySubject[s.ID],
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"group": group.Name, "subjects": subjects, "data": choices})
+	}
+}
+
+// SetElectiveChoices replaces a batch's choices in an elective group with the
+// number of students taking each subject.
+func SetElectiveChoices(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var group models.ElectiveGroup
+		if err := db.Preload("Subjects").First(&group, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+
+		var input struct {
+			BatchID uint `json:"batch_id" binding:"required"`
+			Choices []struct {
+				SubjectID uint `json:"subject_id" binding:"required"`
+				Students  int  `json:"students"`
+			} `json:"choices"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var batch models.Batch
+		if err := db.First(&batch, input.BatchID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "batch not found"})
+			return
+		}
+		if batch.CourseID != group.CourseID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "batch belongs to another course than the elective group"})
+			return
+		}
+
+		inGroup := make(map[uint]bool, len(group.Subjects))
+		subjectIDs := make([]uint, 0, len(group.Subjects))
+		for _, s := range group.Subjects {
+			inGroup[s.ID] = true
+			subjectIDs = append(subjectIDs, s.ID)
+		}
+
+		choices := []models.ElectiveChoice{}
+		chosen := make(map[uint]bool, len(input.Choices))
+		total := 0
+		for i, ch := range input.Choices {
+			if !inGroup[ch.SubjectID] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("choice %d: subject %d is not part of this elective group", i, ch.SubjectID)})
+				return
+			}
+			if chosen[ch.SubjectID] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("choice %d: subject %d is listed twice", i, ch.SubjectID)})
+				return
+			}
+			chosen[ch.SubjectID] = true
+			if ch.Students < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("choice %d: students must not be negative", i)})
+				return
+			}
+			total += ch.Students
+			choices = append(choices, models.ElectiveChoice{
+				BatchID:   batch.ID,
+				SubjectID: ch.SubjectID,
+				Students:  ch.Students,
+			})
+		}
+		if batch.Strength > 0 && total > batch.Strength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%d choices exceed the batch strength of %d", total, batch.Strength)})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Where("batch_id = ? AND subject_id IN ?", batch.ID, subjectIDs).
+				Delete(&models.ElectiveChoice{}).Error
+			if err != nil || len(choices) == 0 {
+				return err
+			}
+			return tx.Omit("Batch", "Subject").Create(&choices).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"batch_id": batch.ID, "data": choices})
+	}
+}
@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"tms-server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportTable answers with a table as a CSV or PDF download when the request
+// asks for it with format=csv or format=pdf, and reports whether it did.
+func exportTable(c *gin.Context, filename, title string, header []string, rows [][]string) bool {
+	switch c.Query("format") {
+	case "csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		_ = w.Write(header)
+		_ = w.WriteAll(rows)
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".csv"))
+		c.Data(http.StatusOK, "text/csv", buf.Bytes())
+		return true
+	case "pdf":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".pdf"))
+		c.Data(http.StatusOK, "application/pdf", utils.TablePDF(title, header, rows))
+		return true
+	}
+	return false
+}
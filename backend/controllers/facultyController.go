@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var availabilityKinds = []string{"available", "preferred", "blocked"}
+
+func GetFacultyAvailability(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var faculty models.Faculty
+		if err := db.First(&faculty, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+
+		windows := []models.FacultyAvailability{}
+		err := db.Where("faculty_id = ?", faculty.ID).
+			Order("kind, day_of_week, start_time").
+			Find(&windows).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"faculty_id": faculty.ID, "data": windows})
+	}
+}
+
+// SetFacultyAvailability replaces all availability windows of a faculty with
+// the ones in the request body.
+func SetFacultyAvailability(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var faculty models.Faculty
+		if err := db.First(&faculty, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+
+		var windows []models.FacultyAvailability
+		if err := c.ShouldBindJSON(&windows); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		for i := range windows {
+			w := &windows[i]
+			w.ID = 0
+			w.FacultyID = faculty.ID
+			if !slices.Contains(availabilityKinds, w.Kind) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("window %d: invalid Kind %q", i, w.Kind), "allowed": availabilityKinds})
+				return
+			}
+			if !validDayOfWeek(w.DayOfWeek) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("window %d: invalid DayOfWeek %q", i, w.DayOfWeek)})
+				return
+			}
+			if w.StartTime >= w.EndTime {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("window %d: StartTime must be before EndTime", i)})
+				return
+			}
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("faculty_id = ?", faculty.ID).Delete(&models.FacultyAvailability{}).Error; err != nil {
+				return err
+			}
+			if len(windows) == 0 {
+				return nil
+			}
+			return tx.Create(&windows).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"faculty_id": faculty.ID, "data": windows})
+	}
+}
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// queryFilter maps a query parameter onto a condition of a list query. A
+// parameter may carry several comma separated values (faculty_id=1,2)
+// unless the filter is single.
+type queryFilter struct {
+	param  string
+	parse  func(string) (any, error)
+	single bool
+	apply  func(db, query *gorm.DB, values []any) (*gorm.DB, error)
+}
+
+// whereIn filters column by the parameter's values.
+func whereIn(column string) func(db, query *gorm.DB, values []any) (*gorm.DB, error) {
+	return func(_, query *gorm.DB, values []any) (*gorm.DB, error) {
+		return query.Where(column+" IN ?", values), nil
+	}
+}
+
+// where filters with a condition taking the parameter's single value.
+func where(condition string) func(db, query *gorm.DB, values []any) (*gorm.DB, error) {
+	return func(_, query *gorm.DB, values []any) (*gorm.DB, error) {
+		return query.Where(condition, values[0]), nil
+	}
+}
+
+func idFilter(param, column string) queryFilter {
+	return queryFilter{param: param, parse: parseID, apply: whereIn(column)}
+}
+
+// batchFilter matches lectures attended by a batch, its parent batch or its
+// groups, also as part of a combined lecture.
+var batchFilter = queryFilter{
+	param: "batch_id",
+	parse: parseID,
+	apply: func(db, query *gorm.DB, values []any) (*gorm.DB, error) {
+		ids := make([]uint, len(values))
+		for i, v := range values {
+			ids[i] = v.(uint)
+		}
+		related, err := relatedBatchIDs(db, ids)
+		if err != nil {
+			return nil, err
+		}
+		return query.Where(lectureHasBatchSQL, related, related), nil
+	},
+}
+
+// attendingBatchFilter filters on a column of the batches attending a
+// lecture: its own batch, the batches combined into it and, for groups, their
+// parent batch. Section A thus also matches the lectures of groups A1 and A2.
+func attendingBatchFilter(param, column string, parse func(string) (any, error)) queryFilter {
+	return queryFilter{
+		param: param,
+		parse: parse,
+		apply: func(_, query *gorm.DB, values []any) (*gorm.DB, error) {
+			return query.Where(`EXISTS (
+				SELECT 1 FROM batches attending
+				LEFT JOIN batches parent ON parent.id = attending.parent_id
+				WHERE `+lectureAttendedBySQL+`
+				AND (attending.`+column+` IN ? OR parent.`+column+` IN ?)
+			)`, values, values), nil
+		},
+	}
+}
+
+func parseID(s string) (any, error) {
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || id == 0 {
+		return nil, fmt.Errorf("%q is not an ID", s)
+	}
+	return uint(id), nil
+}
+
+func parseInt(s string) (any, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a number", s)
+	}
+	return n, nil
+}
+
+func parseText(s string) (any, error) {
+	return s, nil
+}
+
+func parseBool(s string) (any, error) {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not true or false", s)
+	}
+	return b, nil
+}
+
+func parseDay(s string) (any, error) {
+	if !validDayOfWeek(s) {
+		return nil, fmt.Errorf("%q is not a day of the week, e.g. Monday", s)
+	}
+	return s, nil
+}
+
+func parseTime(s string) (any, error) {
+	return models.ParseTimeOfDay(s)
+}
+
+// applyFilters narrows query down by every filter whose parameter is set. It
+// writes a 400 response naming the parameter and returns ok=false when a
+// value is invalid.
+func applyFilters(c *gin.Context, db, query *gorm.DB, filters []queryFilter) (*gorm.DB, bool) {
+	for _, f := range filters {
+		raw := strings.TrimSpace(c.Query(f.param))
+		if raw == "" {
+			continue
+		}
+
+		parts := strings.Split(raw, ",")
+		if f.single && len(parts) > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid '%s' parameter: only one value is allowed", f.param)})
+			return nil, false
+		}
+		values := make([]any, 0, len(parts))
+		for _, part := range parts {
+			v, err := f.parse(strings.TrimSpace(part))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid '%s' parameter: %s", f.param, err)})
+				return nil, false
+			}
+			values = append(values, v)
+		}
+
+		var err error
+		if query, err = f.apply(db, query, values); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return nil, false
+		}
+	}
+	return query, true
+}
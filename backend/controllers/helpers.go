@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const dateLayout = "2006-01-02"
+
+// currentUser loads the user behind the JWT of the current request.
+func currentUser(c *gin.Context, db *gorm.DB) (*models.User, error) {
+	username := c.GetString("username")
+	if username == "" {
+		return nil, errors.New("user not found in request context")
+	}
+
+	var user models.User
+	if err := db.Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// currentFaculty loads the faculty profile linked to the user of the current
+// request.
+func currentFaculty(c *gin.Context, db *gorm.DB) (*models.Faculty, error) {
+	user, err := currentUser(c, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var faculty models.Faculty
+	if err := db.Where("user_id = ?", user.ID).First(&faculty).Error; err != nil {
+		return nil, err
+	}
+	return &faculty, nil
+}
+
+// currentStudent loads the student profile linked to the user of the current
+// request.
+func currentStudent(c *gin.Context, db *gorm.DB) (*models.Student, error) {
+	user, err := currentUser(c, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var student models.Student
+	if err := db.Preload("Electives").Where("user_id = ?", user.ID).First(&student).Error; err != nil {
+		return nil, err
+	}
+	return &student, nil
+}
+
+// isAdmin reports whether the current request was made by an admin.
+func isAdmin(c *gin.Context) bool {
+	role := c.GetString("role")
+	return role == "admin" || role == "superadmin"
+}
+
+// validDayOfWeek reports whether s is a weekday name such as "Monday".
+func validDayOfWeek(s string) bool {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if d.String() == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTimeRange parses "HH:MM" start and end times, reporting ok=false when
+// either is invalid or start is not before end.
+func parseTimeRange(startStr, endStr string) (start, end models.TimeOfDay, ok bool) {
+	start, err := models.ParseTimeOfDay(startStr)
+	if err != nil {
+		return 0, 0, false
+	}
+	end, err = models.ParseTimeOfDay(endStr)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, end, start < end
+}
+
+// parseDateRange reads the required 'from' and 'to' query parameters. It
+// writes a 400 response and returns ok=false when either is missing or invalid.
+func parseDateRange(c *gin.Context) (from, to time.Time, ok bool) {
+	fromStr := c.Query("from")
+	toStr := c.Query("to")
+
+	if fromStr == "" || toStr == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Both 'from' and 'to' query parameters are required (YYYY-MM-DD)."})
+		return from, to, false
+	}
+
+	from, err := time.Parse(dateLayout, fromStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'from' date, use YYYY-MM-DD"})
+		return from, to, false
+	}
+	to, err = time.Parse(dateLayout, toStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'to' date, use YYYY-MM-DD"})
+		return from, to, false
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'to' must not be before 'from'"})
+		return from, to, false
+	}
+
+	return from, to, true
+}
+
+// parseArchived reads the archived query parameter of reports, which leave
+// out the sessions of terms closed by the rollover unless archived=true. It
+// writes a 400 response and returns ok=false when the value is invalid.
+func parseArchived(c *gin.Context) (archived, ok bool) {
+	v, err := parseBool(c.DefaultQuery("archived", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid 'archived' parameter: %s", err)})
+		return false, false
+	}
+	return v.(bool), true
+}
+
+// periodKey names the period a date falls in when grouping by "week"
+// (ISO week, e.g. 2025-W07), "month" (e.g. 2025-02) or "semester", the
+// halves of a year (2025-S1 for January to June, 2025-S2 for July to
+// December).
+func periodKey(d time.Time, groupBy string) string {
+	switch groupBy {
+	case "week":
+		year, week := d.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case "semester":
+		half := 1
+		if d.Month() > time.June {
+			half = 2
+		}
+		return fmt.Sprintf("%d-S%d", d.Year(), half)
+	}
+	return d.Format("2006-01")
+}
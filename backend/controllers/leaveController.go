@@ -0,0 +1,281 @@
+package controllers
+
+import (
+	"net/http"
+	"slices"
+	"time"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var leaveTypes = []string{"casual", "medical", "duty", "other"}
+
+// Statuses given to sessions of a faculty on approved leave that nobody covers yet.
+var leaveFlags = []models.SessionStatus{models.SessionNeedsSubstitution, models.SessionNeedsCancellation}
+
+// leaveFlag decides what an uncovered session of a faculty on leave needs: a
+// substitute if anyone eligible is free, cancellation otherwise. The session's
+// Lecture must be loaded.
+func leaveFlag(db *gorm.DB, session models.Session) (models.SessionStatus, error) {
+	substitutes, err := eligibleSubstitutes(db, session)
+	if err != nil {
+		return "", err
+	}
+	if len(substitutes) > 0 {
+		return models.SessionNeedsSubstitution, nil
+	}
+	return models.SessionNeedsCancellation, nil
+}
+
+// approvedLeaves returns the approved leaves overlapping a date range, keyed
+// by faculty.
+func approvedLeaves(db *gorm.DB, from, to time.Time) (map[uint][]models.Leave, error) {
+	var leaves []models.Leave
+	err := db.Where("status = ?", "approved").
+		Where("start_date <= ? AND end_date >= ?", to, from).
+		Find(&leaves).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byFaculty := make(map[uint][]models.Leave)
+	for _, l := range leaves {
+		byFaculty[l.FacultyID] = append(byFaculty[l.FacultyID], l)
+	}
+	return byFaculty, nil
+}
+
+// leaveOn returns the leave in leaves covering date, if any.
+func leaveOn(leaves []models.Leave, date time.Time) *models.Leave {
+	for i, l := range leaves {
+		if !date.Before(l.StartDate) && !date.After(l.EndDate) {
+			return &leaves[i]
+		}
+	}
+	return nil
+}
+
+// flagLeaveSessions flags the open, uncovered sessions falling in an
+// approved leave.
+func flagLeaveSessions(db *gorm.DB, leave models.Leave) error {
+	var sessions []models.Session
+	err := db.Preload("Lecture").
+		Joins("JOIN lectures ON lectures.id = sessions.lecture_id").
+		Where("lectures.faculty_id = ?", leave.FacultyID).
+		Where("sessions.date BETWEEN ? AND ?", leave.StartDate, leave.EndDate).
+		Where("COALESCE(sessions.status, '') = ''").
+		Where("NOT EXISTS (SELECT 1 FROM substitutions WHERE substitutions.session_id = sessions.id)").
+		Find(&sessions).Error
+	if err != nil {
+		return err
+	}
+
+	for _, s := range sessions {
+		flag, err := leaveFlag(db, s)
+		if err != nil {
+			return err
+		}
+		if err := db.Model(&models.Session{}).Where("id = ?", s.ID).Update("status", flag).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unflagLeaveSessions clears the flags set for a leave that is no longer
+// approved, unless another approved leave still covers the session.
+func unflagLeaveSessions(db *gorm.DB, leave models.Leave) error {
+	return db.Model(&models.Session{}).
+		Where("status IN ?", leaveFlags).
+		Where("date BETWEEN ? AND ?", leave.StartDate, leave.EndDate).
+		Where("lecture_id IN (SELECT id FROM lectures WHERE faculty_id = ?)", leave.FacultyID).
+		Where(`NOT EXISTS (
+			SELECT 1 FROM leaves
+			WHERE leaves.id <> ? AND leaves.faculty_id = ? AND leaves.status = 'approved'
+			AND sessions.date BETWEEN leaves.start_date AND leaves.end_date
+		)`, leave.ID, leave.FacultyID).
+		Update("status", models.SessionScheduled).Error
+}
+
+// QueryLeaves lists leaves. Admins see everyone's, other callers only their
+// own.
+func QueryLeaves(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Preload("Faculty").Order("start_date DESC")
+
+		if !isAdmin(c) {
+			faculty, err := currentFaculty(c, db)
+			if err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": "No faculty profile linked to this user"})
+				return
+			}
+			query = query.Where("faculty_id = ?", faculty.ID)
+		} else if facultyID := c.Query("faculty_id"); facultyID != "" {
+			query = query.Where("faculty_id = ?", facultyID)
+		}
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		if date := c.Query("date"); date != "" {
+			query = query.Where("start_date <= ? AND end_date >= ?", date, date)
+		}
+
+		leaves := []models.Leave{}
+		if err := query.Find(&leaves).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, leaves)
+	}
+}
+
+// GetLeave returns a leave. Non-admins may only read their own.
+func GetLeave(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var leave models.Leave
+		if err := db.Preload("Faculty").First(&leave, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		if !isAdmin(c) {
+			faculty, err := currentFaculty(c, db)
+			if err != nil || faculty.ID != leave.FacultyID {
+				c.JSON(http.StatusForbidden, gin.H{"error": "you can only view your own leaves"})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, leave)
+	}
+}
+
+// RequestLeave records a pending leave. Faculty request leave for themselves,
+// admins may request it on behalf of any faculty.
+func RequestLeave(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			FacultyID uint   `json:"faculty_id"`
+			StartDate string `json:"start_date" binding:"required"`
+			EndDate   string `json:"end_date" binding:"required"`
+			Type      string `json:"type" binding:"required"`
+			Reason    string `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !slices.Contains(leaveTypes, input.Type) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid leave type", "allowed": leaveTypes})
+			return
+		}
+
+		start, err := time.Parse(dateLayout, input.StartDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start_date, use YYYY-MM-DD"})
+			return
+		}
+		end, err := time.Parse(dateLayout, input.EndDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end_date, use YYYY-MM-DD"})
+			return
+		}
+		if end.Before(start) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must not be before start_date"})
+			return
+		}
+
+		facultyID := input.FacultyID
+		if !isAdmin(c) || facultyID == 0 {
+			faculty, err := currentFaculty(c, db)
+			if err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": "No faculty profile linked to this user"})
+				return
+			}
+			facultyID = faculty.ID
+		}
+
+		leave := models.Leave{
+			FacultyID: facultyID,
+			StartDate: start,
+			EndDate:   end,
+			Type:      input.Type,
+			Status:    "pending",
+			Reason:    input.Reason,
+		}
+		if err := db.Omit("Faculty", "ReviewedBy").Create(&leave).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, leave)
+	}
+}
+
+// ReviewLeave approves or rejects a leave. Approving flags the sessions it
+// affects, rejecting a previously approved leave clears those flags again.
+func ReviewLeave(db *gorm.DB, status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var leave models.Leave
+		if err := db.First(&leave, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+
+		user, err := currentUser(c, db)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		wasApproved := leave.Status == "approved"
+		err = db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Model(&leave).Updates(map[string]any{
+				"status":         status,
+				"reviewed_by_id": user.ID,
+			}).Error
+			if err != nil {
+				return err
+			}
+
+			if status == "approved" {
+				return flagLeaveSessions(tx, leave)
+			}
+			if wasApproved {
+				return unflagLeaveSessions(tx, leave)
+			}
+			return nil
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, leave)
+	}
+}
+
+func DeleteLeave(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var leave models.Leave
+		if err := db.First(&leave, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Delete(&leave).Error; err != nil {
+				return err
+			}
+			if leave.Status == "approved" {
+				return unflagLeaveSessions(tx, leave)
+			}
+			return nil
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+	}
+}
@@ -2,72 +2,339 @@ package controllers
 
 import (
 	"net/http"
-	"strconv"
+	"slices"
 	"tms-server/models"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// lectureFilters are the query parameters QueryLectures understands.
+// course_id, year and section match any batch attending a lecture. start and
+// end select the lectures overlapping that time of day.
+var lectureFilters = []queryFilter{
+	attendingBatchFilter("course_id", "course_id", parseID),
+	attendingBatchFilter("year", "year", parseInt),
+	attendingBatchFilter("section", "section", parseText),
+	batchFilter,
+	{param: "semester", parse: parseInt, apply: whereIn("lectures.semester")},
+	idFilter("faculty_id", "lectures.faculty_id"),
+	idFilter("room_id", "lectures.room_id"),
+	idFilter("subject_id", "lectures.subject_id"),
+	{param: "day_of_week", parse: parseDay, apply: whereIn("lectures.day_of_week")},
+	{param: "start", parse: parseTime, single: true, apply: where("lectures.end_time > ?")},
+	{param: "end", parse: parseTime, single: true, apply: where("lectures.start_time < ?")},
+	{param: "archived", parse: parseBool, single: true, apply: where("lectures.archived = ?")},
+}
+
+// dayOrderSQL sorts rows by a day of the week column, Monday first rather
+// than alphabetically.
+func dayOrderSQL(column string) string {
+	return `CASE ` + column + `
+	WHEN 'Monday' THEN 1 WHEN 'Tuesday' THEN 2 WHEN 'Wednesday' THEN 3
+	WHEN 'Thursday' THEN 4 WHEN 'Friday' THEN 5 WHEN 'Saturday' THEN 6
+	ELSE 7 END`
+}
+
+// QueryLectures lists the timetable entries matching the lectureFilters, in
+// weekly order. Most filters take comma separated values, e.g. faculty_id=1,2.
+// Archived lectures are only listed with archived=true.
 func QueryLectures(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		query := db.Preload("Batch").Preload("Batches").Preload("Subject").Preload("Faculty").Preload("Room").
+			Order(dayOrderSQL("lectures.day_of_week")).
+			Order("lectures.start_time")
+		if c.Query("archived") == "" {
+			query = query.Where(activeLectureSQL)
+		}
+
+		query, ok := applyFilters(c, db, query, lectureFilters)
+		if !ok {
+			return
+		}
+
 		var lectures []models.Lecture
+		if err := query.Find(&lectures).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 
-		courseIDStr := c.Query("course_id")
-		yearStr := c.Query("year")
-		section := c.Query("section")
-		semesterStr := c.Query("semester")
-		facultyIDStr := c.Query("faculty_id")
-		roomIDStr := c.Query("room_id")
+		c.JSON(http.StatusOK, lectures)
+	}
+}
 
-		query := db.Preload("Batch").Preload("Subject").Preload("Faculty").Preload("Room").
-			Joins("JOIN batches ON batches.id = lectures.batch_id")
+// writeLecture saves a validated lecture together with the combined batches
+// attending it.
+func writeLecture(tx *gorm.DB, l *models.Lecture) error {
+	if err := tx.Omit(clause.Associations).Save(l).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("lecture_id = ?", l.ID).Delete(&models.LectureBatch{}).Error; err != nil {
+		return err
+	}
 
-		if semesterStr != "" {
-			if semester, err := strconv.Atoi(semesterStr); err == nil {
-				query = query.Where("semester = ?", semester)
-			}
+	rows := []models.LectureBatch{}
+	for _, id := range lectureBatchIDs(*l) {
+		if id != l.BatchID {
+			rows = append(rows, models.LectureBatch{LectureID: l.ID, BatchID: id})
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.Create(&rows).Error
+}
+
+// saveLecture validates a lecture and writes it, answering with the saved
+// lecture and any warnings, or with the blocking issues if it was rejected.
+func saveLecture(c *gin.Context, db *gorm.DB, lecture *models.Lecture, status int) {
+	blocking, warnings, err := validateLecture(db, lecture)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to validate lecture"})
+		return
+	}
+	if len(blocking) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "lecture violates scheduling rules", "issues": blocking})
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return writeLecture(tx, lecture)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(status, gin.H{"data": lecture, "warnings": warnings})
+}
+
+func CreateLecture(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var lecture models.Lecture
+		if err := c.ShouldBindJSON(&lecture); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		lecture.ID = 0
+		lecture.Archived = false
+		if draftOpenFor(c, db, lecture) {
+			return
+		}
+		saveLecture(c, db, &lecture, http.StatusCreated)
+	}
+}
+
+func UpdateLecture(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var lecture models.Lecture
+		if err := db.Preload("Batches").First(&lecture, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		if lecture.Archived {
+			c.JSON(http.StatusConflict, gin.H{"error": "archived lectures can't be changed"})
+			return
+		}
+		before := lecture
+		if err := c.ShouldBindJSON(&lecture); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		lecture.ID = before.ID
+		lecture.Archived = false
+		if draftOpenFor(c, db, before, lecture) {
+			return
+		}
+		saveLecture(c, db, &lecture, http.StatusOK)
+	}
+}
+
+// DeleteLecture takes a lecture off the timetable, together with the batches
+// combined into it. A lecture whose sessions have already begun is archived
+// to keep them as history rather than deleted; see retireLecture.
+func DeleteLecture(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var lecture models.Lecture
+		if err := db.First(&lecture, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		if lecture.Archived {
+			c.JSON(http.StatusConflict, gin.H{"error": "archived lectures can't be changed"})
+			return
+		}
+		if draftOpenFor(c, db, lecture) {
+			return
 		}
 
-		if facultyIDStr != "" {
-			if facultyID, err := strconv.Atoi(facultyIDStr); err == nil {
-				query = query.Where("faculty_id = ?", facultyID)
+		var dropped int64
+		var archived bool
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if dropped, err = retireLecture(tx, lecture.ID); err != nil {
+				return err
 			}
+			return tx.Model(&models.Lecture{}).Select("archived").Where("id = ?", lecture.ID).Scan(&archived).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		message := "Deleted"
+		if archived {
+			message = "Archived"
 		}
+		c.JSON(http.StatusOK, gin.H{"message": message, "dropped_sessions": dropped})
+	}
+}
 
-		if roomIDStr != "" {
-			if roomID, err := strconv.Atoi(roomIDStr); err == nil {
-				query = query.Where("room_id = ?", roomID)
+// MergeLectures turns back-to-back lecture rows of the same class into one
+// multi-period block. The sessions of the merged rows are moved onto the
+// block, keeping one regular session per date; the merge is refused when a
+// session that would be dropped has anything recorded.
+func MergeLectures(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			LectureIDs []uint `json:"lecture_ids" binding:"required,min=2"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var lectures []models.Lecture
+		if err := db.Preload("Batches").Where("id IN ?", input.LectureIDs).Order("start_time").Find(&lectures).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(lectures) != len(input.LectureIDs) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "some lectures were not found"})
+			return
+		}
+		for _, l := range lectures {
+			if l.Archived {
+				c.JSON(http.StatusConflict, gin.H{"error": "archived lectures can't be merged"})
+				return
 			}
 		}
+		if draftOpenFor(c, db, lectures...) {
+			return
+		}
 
-		if yearStr != "" {
-			if year, err := strconv.Atoi(yearStr); err == nil {
-				query = query.Where("batches.year = ?", year)
-			} else {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year parameter"})
+		block := lectures[0]
+		for i, l := range lectures[1:] {
+			prev := lectures[i]
+			if l.DayOfWeek != block.DayOfWeek || l.SubjectID != block.SubjectID ||
+				l.FacultyID != block.FacultyID || l.BatchID != block.BatchID ||
+				l.RoomID != block.RoomID || l.Semester != block.Semester ||
+				!sameBatches(l, block) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "lectures must share day, subject, faculty, batches, room and semester"})
+				return
+			}
+			if prev.EndTime != l.StartTime {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "lectures must follow each other without a gap"})
 				return
 			}
+			block.EndTime = l.EndTime
 		}
 
-		if courseIDStr != "" {
-			if courseID, err := strconv.Atoi(courseIDStr); err == nil {
-				query = query.Where("batches.course_id = ?", courseID)
-			} else {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course_id parameter"})
+		// With a period grid the lectures must be adjacent periods, with no
+		// break between them.
+		var batch models.Batch
+		if err := db.First(&batch, block.BatchID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		grid, err := timeSlots(db, batch.CourseID, block.DayOfWeek)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(grid) > 0 {
+			periods := 0
+			for _, l := range lectures {
+				periods += spannedPeriods(grid, l.StartTime, l.EndTime)
+			}
+			if spannedPeriods(grid, block.StartTime, block.EndTime) != periods {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "lectures must be adjacent periods"})
 				return
 			}
 		}
 
-		if section != "" {
-			query = query.Where("batches.section = ?", section)
+		// Sessions the block already has on a date replace those of the merged
+		// lectures, which must have nothing worth keeping.
+		ids := make([]uint, 0, len(lectures)-1)
+		for _, l := range lectures[1:] {
+			ids = append(ids, l.ID)
+		}
+		var colliding int64
+		err = db.Model(&models.Session{}).
+			Where("lecture_id IN ?", ids).
+			Where("date IN (?)", db.Model(&models.Session{}).Select("date").Where("lecture_id = ?", block.ID)).
+			Where("NOT ("+oneOffSessionSQL+")").
+			Where("(COALESCE(status, '') NOT IN ? OR COALESCE(notes, '') <> '' OR "+sessionRecordsSQL+")", openSessionStatuses).
+			Count(&colliding).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if colliding > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "the lectures have sessions on the same date with records that would be lost"})
+			return
 		}
 
-		if err := query.Find(&lectures).Error; err != nil {
+		var blocking, warnings []lectureIssue
+		err = db.Transaction(func(tx *gorm.DB) error {
+			for _, l := range lectures[1:] {
+				// Move sessions onto the block unless it already has one that
+				// day. One-off sessions keep their own slot and always move.
+				err := tx.Model(&models.Session{}).
+					Where("lecture_id = ?", l.ID).
+					Where("(date NOT IN (?) OR "+oneOffSessionSQL+")", tx.Model(&models.Session{}).Select("date").Where("lecture_id = ?", block.ID)).
+					Update("lecture_id", block.ID).Error
+				if err != nil {
+					return err
+				}
+				if err := tx.Where("lecture_id = ?", l.ID).Delete(&models.Session{}).Error; err != nil {
+					return err
+				}
+				if err := tx.Where("lecture_id = ?", l.ID).Delete(&models.LectureBatch{}).Error; err != nil {
+					return err
+				}
+				if err := tx.Delete(&models.Lecture{}, l.ID).Error; err != nil {
+					return err
+				}
+			}
+
+			var err error
+			blocking, warnings, err = validateLecture(tx, &block)
+			if err != nil {
+				return err
+			}
+			if len(blocking) > 0 {
+				return errLectureRejected
+			}
+			return writeLecture(tx, &block)
+		})
+		if len(blocking) > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "lecture violates scheduling rules", "issues": blocking})
+			return
+		}
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
-		c.JSON(http.StatusOK, lectures)
+		c.JSON(http.StatusOK, gin.H{"data": block, "warnings": warnings})
 	}
 }
+
+// sameBatches reports whether two lectures are attended by the same batches.
+func sameBatches(a, b models.Lecture) bool {
+	aIDs, bIDs := lectureBatchIDs(a), lectureBatchIDs(b)
+	slices.Sort(aIDs)
+	slices.Sort(bIDs)
+	return slices.Equal(aIDs, bIDs)
+}
@@ -0,0 +1,447 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"tms-server/config"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errLectureRejected aborts a transaction when a lecture has blocking issues.
+var errLectureRejected = errors.New("lecture violates scheduling rules")
+
+// lectureIssue is a problem found while validating a lecture write. Blocking
+// issues reject the write, the others are returned as warnings.
+type lectureIssue struct {
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+	Details  any    `json:"details,omitempty"`
+	Blocking bool   `json:"-"`
+}
+
+// lectureCheck inspects a lecture about to be written and may fill in its
+// derived fields. Checks must ignore the stored row with the lecture's own ID.
+type lectureCheck func(db *gorm.DB, l *models.Lecture) ([]lectureIssue, error)
+
+var lectureChecks = []lectureCheck{
+	checkLectureFields,
+	checkPeriodGrid,
+	checkLectureClashes,
+	checkFacultyAvailability,
+	checkFacultyWorkload,
+	checkRoomCapacity,
+	checkRoomRequirements,
+}
+
+// roomChecks are the lecture checks concerning its room alone, which one-off
+// sessions in a room of their own go through as well.
+var roomChecks = []lectureCheck{
+	checkRoomCapacity,
+	checkRoomRequirements,
+}
+
+// validateLecture runs all lecture checks and splits the issues found into
+// blocking ones and warnings.
+func validateLecture(db *gorm.DB, l *models.Lecture) (blocking, warnings []lectureIssue, err error) {
+	return runLectureChecks(db, l, lectureChecks)
+}
+
+// runLectureChecks runs checks in order, as validateLecture does.
+func runLectureChecks(db *gorm.DB, l *models.Lecture, checks []lectureCheck) (blocking, warnings []lectureIssue, err error) {
+	blocking, warnings = []lectureIssue{}, []lectureIssue{}
+	for _, check := range checks {
+		issues, err := check(db, l)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, issue := range issues {
+			if issue.Blocking {
+				blocking = append(blocking, issue)
+			} else {
+				warnings = append(warnings, issue)
+			}
+		}
+		// Later checks rely on well-formed fields.
+		if len(blocking) > 0 {
+			break
+		}
+	}
+	return blocking, warnings, nil
+}
+
+func checkLectureFields(db *gorm.DB, l *models.Lecture) ([]lectureIssue, error) {
+	issues := []lectureIssue{}
+	if !validDayOfWeek(l.DayOfWeek) {
+		issues = append(issues, lectureIssue{
+			Rule:     "fields",
+			Message:  fmt.Sprintf("invalid DayOfWeek %q", l.DayOfWeek),
+			Blocking: true,
+		})
+	}
+	if len(l.Batches) > 0 {
+		ids := []uint{}
+		for _, b := range l.Batches {
+			if b.ID != l.BatchID && !slices.Contains(ids, b.ID) {
+				ids = append(ids, b.ID)
+			}
+		}
+		var batches []models.Batch
+		if err := db.Where("id IN ?", ids).Find(&batches).Error; err != nil {
+			return nil, err
+		}
+		if len(batches) != len(ids) {
+			issues = append(issues, lectureIssue{
+				Rule:     "fields",
+				Message:  "some combined batches were not found",
+				Blocking: true,
+			})
+		}
+		l.Batches = batches
+	}
+	if l.StartTime >= l.EndTime {
+		issues = append(issues, lectureIssue{
+			Rule:     "fields",
+			Message:  "StartTime must be before EndTime",
+			Blocking: true,
+		})
+	}
+	return issues, nil
+}
+
+// checkPeriodGrid requires lectures to start and end on period boundaries of
+// the time slots configured for their course and day, and records how many
+// periods they span. Without configured slots any times are accepted.
+func checkPeriodGrid(db *gorm.DB, l *models.Lecture) ([]lectureIssue, error) {
+	var batch models.Batch
+	if err := db.First(&batch, l.BatchID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []lectureIssue{{Rule: "period_grid", Message: "batch not found", Blocking: true}}, nil
+		}
+		return nil, err
+	}
+
+	grid, err := timeSlots(db, batch.CourseID, l.DayOfWeek)
+	if err != nil {
+		return nil, err
+	}
+	if len(grid) == 0 {
+		l.Periods = 1
+		return nil, nil
+	}
+
+	n := spannedPeriods(grid, l.StartTime, l.EndTime)
+	if n == 0 {
+		return []lectureIssue{{
+			Rule:     "period_grid",
+			Message:  fmt.Sprintf("%s-%s does not match the period grid on %s", l.StartTime, l.EndTime, l.DayOfWeek),
+			Details:  gin.H{"time_slots": grid},
+			Blocking: true,
+		}}, nil
+	}
+	l.Periods = uint(n)
+	return nil, nil
+}
+
+// checkLectureClashes rejects lectures overlapping another lecture of the same
+// room, faculty or batch.
+func checkLectureClashes(db *gorm.DB, l *models.Lecture) ([]lectureIssue, error) {
+	conflicts, err := findLectureClashes(db, l)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) == 0 {
+		return nil, nil
+	}
+	return []lectureIssue{{
+		Rule:     "clash",
+		Message:  "lecture clashes with existing lectures",
+		Details:  gin.H{"conflicts": conflicts},
+		Blocking: true,
+	}}, nil
+}
+
+// checkFacultyAvailability compares a lecture with its faculty's availability
+// windows. Lectures outside declared available windows or overlapping a
+// blocked one break the rule, which APP_AVAILABILITY_ENFORCEMENT decides to
+// warn about or reject. Missing a preferred slot is only ever a warning.
+func checkFacultyAvailability(db *gorm.DB, l *models.Lecture) ([]lectureIssue, error) {
+	var windows []models.FacultyAvailability
+	if err := db.Where("faculty_id = ?", l.FacultyID).Find(&windows).Error; err != nil {
+		return nil, err
+	}
+
+	var hasAvailable, inAvailable, hasPreferred, inPreferred bool
+	var blocked []models.FacultyAvailability
+	for _, w := range windows {
+		sameDay := w.DayOfWeek == l.DayOfWeek
+		within := sameDay && w.StartTime <= l.StartTime && l.EndTime <= w.EndTime
+		switch w.Kind {
+		case "available":
+			hasAvailable = true
+			inAvailable = inAvailable || within
+		case "preferred":
+			hasPreferred = true
+			inPreferred = inPreferred || within
+		case "blocked":
+			if sameDay && w.StartTime < l.EndTime && l.StartTime < w.EndTime {
+				blocked = append(blocked, w)
+			}
+		}
+	}
+
+	strict := config.Strict("APP_AVAILABILITY_ENFORCEMENT")
+	issues := []lectureIssue{}
+	if hasAvailable && !inAvailable {
+		issues = append(issues, lectureIssue{
+			Rule:     "availability",
+			Message:  "faculty is not available at this slot",
+			Blocking: strict,
+		})
+	}
+	if len(blocked) > 0 {
+		issues = append(issues, lectureIssue{
+			Rule:     "availability",
+			Message:  "slot overlaps a blocked period of the faculty",
+			Details:  blocked,
+			Blocking: strict,
+		})
+	}
+	if hasPreferred && !inPreferred {
+		issues = append(issues, lectureIssue{
+			Rule:    "availability",
+			Message: "slot is outside the faculty's preferred slots",
+		})
+	}
+	return issues, nil
+}
+
+// workloadLimits resolves the limits of a faculty, falling back to the ones of
+// its designation. A zero limit means none applies.
+func workloadLimits(db *gorm.DB, faculty models.Faculty) (maxWeeklyHours float64, maxConsecutive int, err error) {
+	if faculty.Designation != "" {
+		var limit models.DesignationLimit
+		err := db.Where("designation = ?", faculty.Designation).First(&limit).Error
+		if err == nil {
+			maxWeeklyHours, maxConsecutive = limit.MaxWeeklyHours, limit.MaxConsecutivePeriods
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, 0, err
+		}
+	}
+	if faculty.MaxWeeklyHours != nil {
+		maxWeeklyHours = *faculty.MaxWeeklyHours
+	}
+	if faculty.MaxConsecutivePeriods != nil {
+		maxConsecutive = *faculty.MaxConsecutivePeriods
+	}
+	return maxWeeklyHours, maxConsecutive, nil
+}
+
+// checkFacultyWorkload checks that the faculty's weekly teaching hours and
+// longest run of back-to-back periods stay within its limits, enforced as
+// configured by APP_WORKLOAD_ENFORCEMENT.
+func checkFacultyWorkload(db *gorm.DB, l *models.Lecture) ([]lectureIssue, error) {
+	var faculty models.Faculty
+	if err := db.First(&faculty, l.FacultyID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []lectureIssue{{Rule: "workload", Message: "faculty not found", Blocking: true}}, nil
+		}
+		return nil, err
+	}
+
+	maxWeeklyHours, maxConsecutive, err := workloadLimits(db, faculty)
+	if err != nil {
+		return nil, err
+	}
+	if maxWeeklyHours == 0 && maxConsecutive == 0 {
+		return nil, nil
+	}
+
+	var lectures []models.Lecture
+	if err := db.Where(activeLectureSQL).Where("faculty_id = ? AND id <> ?", l.FacultyID, l.ID).Find(&lectures).Error; err != nil {
+		return nil, err
+	}
+	lectures = append(lectures, *l)
+
+	strict := config.Strict("APP_WORKLOAD_ENFORCEMENT")
+	issues := []lectureIssue{}
+
+	minutes := 0
+	for _, other := range lectures {
+		minutes += int(other.EndTime - other.StartTime)
+	}
+	if hours := float64(minutes) / 60; maxWeeklyHours > 0 && hours > maxWeeklyHours {
+		issues = append(issues, lectureIssue{
+			Rule:     "workload",
+			Message:  fmt.Sprintf("faculty would teach %.1f hours a week, limit is %.1f", hours, maxWeeklyHours),
+			Blocking: strict,
+		})
+	}
+
+	if run := consecutivePeriods(lectures, l); maxConsecutive > 0 && run > maxConsecutive {
+		issues = append(issues, lectureIssue{
+			Rule:     "workload",
+			Message:  fmt.Sprintf("faculty would teach %d consecutive periods on %s, limit is %d", run, l.DayOfWeek, maxConsecutive),
+			Blocking: strict,
+		})
+	}
+	return issues, nil
+}
+
+// consecutivePeriods returns how many periods the run of back-to-back lectures
+// containing l on its day spans.
+func consecutivePeriods(lectures []models.Lecture, l *models.Lecture) int {
+	day := []models.Lecture{}
+	for _, other := range lectures {
+		if other.DayOfWeek == l.DayOfWeek {
+			day = append(day, other)
+		}
+	}
+	sort.Slice(day, func(i, j int) bool { return day[i].StartTime < day[j].StartTime })
+
+	run, found := 0, false
+	for i, other := range day {
+		if i == 0 || day[i-1].EndTime != other.StartTime {
+			if found {
+				break
+			}
+			run = 0
+		}
+		run += max(int(other.Periods), 1)
+		if other.ID == l.ID && other.StartTime == l.StartTime {
+			found = true
+		}
+	}
+	return run
+}
+
+// lectureStrength returns how many students attend a lecture, summed over
+// all batches and groups combined into it. For an elective, batches that
+// recorded their students' choices only count those who chose its subject.
+func lectureStrength(db *gorm.DB, l *models.Lecture) (int, error) {
+	var batches []models.Batch
+	if err := db.Where("id IN ?", lectureBatchIDs(*l)).Find(&batches).Error; err != nil {
+		return 0, err
+	}
+	if len(batches) == 0 {
+		return 0, gorm.ErrRecordNotFound
+	}
+
+	chosen, err := electiveStudents(db, l.SubjectID, lectureBatchIDs(*l))
+	if err != nil {
+		return 0, err
+	}
+
+	strength := 0
+	for _, b := range batches {
+		if n, ok := chosen[b.ID]; ok {
+			strength += n
+		} else {
+			strength += b.Strength
+		}
+	}
+	return strength, nil
+}
+
+// checkRoomCapacity checks that the lecture's room can seat its students,
+// enforced as configured by APP_CAPACITY_ENFORCEMENT. Rooms or batches without
+// a capacity or strength are not checked.
+func checkRoomCapacity(db *gorm.DB, l *models.Lecture) ([]lectureIssue, error) {
+	var room models.Room
+	if err := db.First(&room, l.RoomID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []lectureIssue{{Rule: "capacity", Message: "room not found", Blocking: true}}, nil
+		}
+		return nil, err
+	}
+
+	strength, err := lectureStrength(db, l)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []lectureIssue{{Rule: "capacity", Message: "batch not found", Blocking: true}}, nil
+		}
+		return nil, err
+	}
+	if room.Capacity == 0 || strength <= room.Capacity {
+		return nil, nil
+	}
+
+	fitting, err := suggestRooms(db, l, strength)
+	if err != nil {
+		return nil, err
+	}
+
+	return []lectureIssue{{
+		Rule:     "capacity",
+		Message:  fmt.Sprintf("room %s seats %d but %d students attend", room.Name, room.Capacity, strength),
+		Details:  gin.H{"rooms_that_fit": fitting},
+		Blocking: config.Strict("APP_CAPACITY_ENFORCEMENT"),
+	}}, nil
+}
+
+// suggestRooms returns the rooms free at the lecture's weekly slot that meet
+// its subject's requirements and seat at least minCapacity students.
+func suggestRooms(db *gorm.DB, l *models.Lecture, minCapacity int) ([]models.Room, error) {
+	var subject models.Subject
+	if err := db.First(&subject, l.SubjectID).Error; err != nil {
+		return nil, err
+	}
+	return freeRooms(db, roomQuery{
+		Day:              l.DayOfWeek,
+		StartTime:        l.StartTime,
+		EndTime:          l.EndTime,
+		MinCapacity:      minCapacity,
+		Type:             subject.RequiredRoomType,
+		Features:         subject.RequiredFeatures,
+		ExcludeLectureID: l.ID,
+	})
+}
+
+// checkRoomRequirements rejects lectures placed in a room of another type
+// than their subject requires or lacking one of its required features.
+func checkRoomRequirements(db *gorm.DB, l *models.Lecture) ([]lectureIssue, error) {
+	var subject models.Subject
+	if err := db.First(&subject, l.SubjectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []lectureIssue{{Rule: "room_requirements", Message: "subject not found", Blocking: true}}, nil
+		}
+		return nil, err
+	}
+
+	var room models.Room
+	if err := db.First(&room, l.RoomID).Error; err != nil {
+		return nil, err
+	}
+
+	problems := []string{}
+	if subject.RequiredRoomType != "" && room.Type != subject.RequiredRoomType {
+		problems = append(problems, fmt.Sprintf("%s needs a %s, %s is a %s", subject.Name, subject.RequiredRoomType, room.Name, room.Type))
+	}
+	if missing := room.Features.Missing(subject.RequiredFeatures); len(missing) > 0 {
+		problems = append(problems, fmt.Sprintf("%s lacks %s", room.Name, strings.Join(missing, ", ")))
+	}
+	if len(problems) == 0 {
+		return nil, nil
+	}
+
+	strength, err := lectureStrength(db, l)
+	if err != nil {
+		return nil, err
+	}
+	fitting, err := suggestRooms(db, l, strength)
+	if err != nil {
+		return nil, err
+	}
+
+	return []lectureIssue{{
+		Rule:     "room_requirements",
+		Message:  strings.Join(problems, "; "),
+		Details:  gin.H{"rooms_that_fit": fitting},
+		Blocking: true,
+	}}, nil
+}
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// timeSlots returns the slots of the period grid that apply to a course,
+// ordered by start time. A course with slots of its own uses only those,
+// otherwise the institute-wide slots apply. A non-empty day keeps only the
+// slots applicable on that day.
+func timeSlots(db *gorm.DB, courseID uint, day string) ([]models.TimeSlot, error) {
+	var slots []models.TimeSlot
+	if courseID != 0 {
+		if err := db.Where("course_id = ?", courseID).Order("start_time").Find(&slots).Error; err != nil {
+			return nil, err
+		}
+	}
+	if len(slots) == 0 {
+		if err := db.Where("course_id IS NULL").Order("start_time").Find(&slots).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if day == "" {
+		return slots, nil
+	}
+	onDay := []models.TimeSlot{}
+	for _, s := range slots {
+		if len(s.Days) == 0 || slices.Contains(s.Days, strings.ToLower(day)) {
+			onDay = append(onDay, s)
+		}
+	}
+	return onDay, nil
+}
+
+// spannedPeriods returns how many consecutive periods of grid run from start
+// to end, or 0 if start and end don't fall on period boundaries or the range
+// crosses a break.
+func spannedPeriods(grid []models.TimeSlot, start, end models.TimeOfDay) int {
+	n := 0
+	for _, s := range grid {
+		if n == 0 && (s.StartTime != start || s.IsBreak) {
+			continue
+		}
+		if s.IsBreak || s.EndTime > end {
+			return 0
+		}
+		n++
+		if s.EndTime == end {
+			return n
+		}
+	}
+	return 0
+}
+
+// QueryTimeSlots returns the period grid, optionally the one applying to a
+// course (course_id) on a day (day=Monday).
+func QueryTimeSlots(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		day := c.Query("day")
+		if day != "" && !validDayOfWeek(day) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'day' parameter, e.g. Monday"})
+			return
+		}
+
+		var courseID uint
+		if s := c.Query("course_id"); s != "" {
+			id, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'course_id' parameter"})
+				return
+			}
+			courseID = uint(id)
+		}
+
+		var slots []models.TimeSlot
+		var err error
+		if courseID == 0 && day == "" {
+			slots = []models.TimeSlot{}
+			err = db.Order("course_id NULLS FIRST, start_time").Find(&slots).Error
+		} else {
+			slots, err = timeSlots(db, courseID, day)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, slots)
+	}
+}
@@ -0,0 +1,866 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// effectiveFacultySQL resolves who actually took a session: the substitute if
+// one was assigned, the lecture's faculty otherwise. Queries using it must join
+// lectures and left join substitutions.
+const effectiveFacultySQL = "COALESCE(substitutions.substitute_faculty_id, lectures.faculty_id)"
+
+// GetSubstitutionReport counts, per faculty, the held sessions they taught
+// (crediting substitutes rather than the faculty on the timetable) and the
+// substitutions they took on or handed over within a date range. Sessions of
+// terms closed by the rollover are only counted with archived=true.
+func GetSubstitutionReport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		from, to, ok := parseDateRange(c)
+		if !ok {
+			return
+		}
+		archived, ok := parseArchived(c)
+		if !ok {
+			return
+		}
+		facultyID := c.Query("faculty_id")
+
+		type row struct {
+			FacultyID          uint   `json:"faculty_id"`
+			Faculty            string `json:"faculty"`
+			HeldSessions       int    `json:"held_sessions"`
+			SubstitutionsTaken int    `json:"substitutions_taken"`
+			SubstitutionsGiven int    `json:"substitutions_given"`
+		}
+
+		// Sessions count for whoever took them, substitutions given for the
+		// faculty on the timetable.
+		taught := db.Table("sessions").
+			Select(effectiveFacultySQL+` AS faculty_id,
+				COUNT(*) FILTER (WHERE sessions.status = 'held') AS held_sessions,
+				COUNT(substitutions.id) AS substitutions_taken`).
+			Joins("JOIN lectures ON lectures.id = sessions.lecture_id").
+			Joins("LEFT JOIN substitutions ON substitutions.session_id = sessions.id").
+			Where("sessions.date BETWEEN ? AND ?", from, to).
+			Where("sessions.archived = ?", archived).
+			Group(effectiveFacultySQL)
+		given := db.Table("substitutions").
+			Select("substitutions.original_faculty_id AS faculty_id, COUNT(*) AS substitutions_given").
+			Joins("JOIN sessions ON sessions.id = substitutions.session_id").
+			Where("sessions.date BETWEEN ? AND ?", from, to).
+			Where("sessions.archived = ?", archived).
+			Group("substitutions.original_faculty_id")
+
+		query := db.Model(&models.Faculty{}).
+			Select(`faculties.id AS faculty_id, faculties.name AS faculty,
+				COALESCE(taught.held_sessions, 0) AS held_sessions,
+				COALESCE(taught.substitutions_taken, 0) AS substitutions_taken,
+				COALESCE(given.substitutions_given, 0) AS substitutions_given`).
+			Joins("LEFT JOIN (?) taught ON taught.faculty_id = faculties.id", taught).
+			Joins("LEFT JOIN (?) given ON given.faculty_id = faculties.id", given).
+			Order("faculties.name")
+
+		if facultyID != "" {
+			query = query.Where("faculties.id = ?", facultyID)
+		}
+
+		rows := []row{}
+		if err := query.Scan(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build substitution report"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"from": from.Format(dateLayout),
+			"to":   to.Format(dateLayout),
+			"data": rows,
+		})
+	}
+}
+
+// weekdayCount returns how many times a weekday occurs between from and to,
+// both inclusive.
+func weekdayCount(from, to time.Time, day string) int {
+	count := 0
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if d.Weekday().String() == day {
+			count++
+		}
+	}
+	return count
+}
+
+func roundHours(minutes int) float64 {
+	return math.Round(float64(minutes)/60*100) / 100
+}
+
+// percent returns n as a percentage of total rounded to two decimals, or 0
+// when total is 0.
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(n)/float64(total)*10000) / 100
+}
+
+// GetWorkloadReport compares, per faculty, the hours planned by the weekly
+// lecture grid over a date range with the hours actually taught in held
+// sessions, crediting substitutes for the sessions they covered. With
+// archived=true it compares the archived lectures and sessions of closed
+// terms instead.
+func GetWorkloadReport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		from, to, ok := parseDateRange(c)
+		if !ok {
+			return
+		}
+		archived, ok := parseArchived(c)
+		if !ok {
+			return
+		}
+		facultyID := c.Query("faculty_id")
+
+		facultyQuery := db.Order("name")
+		if facultyID != "" {
+			facultyQuery = facultyQuery.Where("id = ?", facultyID)
+		}
+		var faculties []models.Faculty
+		if err := facultyQuery.Find(&faculties).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch faculties"})
+			return
+		}
+
+		var lectures []models.Lecture
+		if err := db.Where("lectures.archived = ?", archived).Find(&lectures).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch lectures"})
+			return
+		}
+
+		var held []models.Session
+		err := db.Preload("Lecture").Preload("Substitution").
+			Where("date BETWEEN ? AND ?", from, to).
+			Where("status = ?", models.SessionHeld).
+			Where("archived = ?", archived).
+			Find(&held).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sessions"})
+			return
+		}
+
+		weeklyMinutes := make(map[uint]int)
+		plannedMinutes := make(map[uint]int)
+		for _, l := range lectures {
+			duration := int(l.EndTime - l.StartTime)
+			weeklyMinutes[l.FacultyID] += duration
+			plannedMinutes[l.FacultyID] += duration * weekdayCount(from, to, l.DayOfWeek)
+		}
+
+		actualMinutes := make(map[uint]int)
+		heldSessions := make(map[uint]int)
+		for _, s := range held {
+			credited := s.Lecture.FacultyID
+			if s.Substitution != nil {
+				credited = s.Substitution.SubstituteFacultyID
+			}
+			start, end, _ := s.Slot()
+			actualMinutes[credited] += int(end - start)
+			heldSessions[credited]++
+		}
+
+		result := []gin.H{}
+		for _, f := range faculties {
+			maxWeeklyHours, maxConsecutive, err := workloadLimits(db, f)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to resolve workload limits"})
+				return
+			}
+			result = append(result, gin.H{
+				"faculty_id":              f.ID,
+				"faculty":                 f.Name,
+				"designation":             f.Designation,
+				"weekly_hours":            roundHours(weeklyMinutes[f.ID]),
+				"max_weekly_hours":        maxWeeklyHours,
+				"max_consecutive_periods": maxConsecutive,
+				"planned_hours":           roundHours(plannedMinutes[f.ID]),
+				"actual_hours":            roundHours(actualMinutes[f.ID]),
+				"held_sessions":           heldSessions[f.ID],
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"from": from.Format(dateLayout),
+			"to":   to.Format(dateLayout),
+			"data": result,
+		})
+	}
+}
+
+// teachingDays are the weekdays an institute-wide time slot without explicit
+// days applies to.
+var teachingDays = []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// weeklyPeriods counts the teaching periods in a week. With a configured
+// institute-wide period grid that is every non-break slot on each day it
+// applies to, otherwise every distinct day and time slot used in the lecture
+// grid.
+func weeklyPeriods(db *gorm.DB, lectures []models.Lecture) (int, error) {
+	slots, err := timeSlots(db, 0, "")
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	if len(slots) > 0 {
+		for _, s := range slots {
+			if s.IsBreak {
+				continue
+			}
+			if len(s.Days) == 0 {
+				total += len(teachingDays)
+			} else {
+				total += len(s.Days)
+			}
+		}
+		return total, nil
+	}
+
+	type slot struct {
+		day        string
+		start, end models.TimeOfDay
+	}
+	seen := make(map[slot]bool)
+	for _, l := range lectures {
+		key := slot{l.DayOfWeek, l.StartTime, l.EndTime}
+		if !seen[key] {
+			seen[key] = true
+			total += max(int(l.Periods), 1)
+		}
+	}
+	return total, nil
+}
+
+// GetRoomUtilizationReport reports, per room, how many of the weekly periods
+// are occupied by lectures.
+func GetRoomUtilizationReport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var rooms []models.Room
+		if err := db.Order("name").Find(&rooms).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch rooms"})
+			return
+		}
+
+		var lectures []models.Lecture
+		if err := db.Where(activeLectureSQL).Find(&lectures).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch lectures"})
+			return
+		}
+
+		periods, err := weeklyPeriods(db, lectures)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch time slots"})
+			return
+		}
+
+		occupied := make(map[uint]int)
+		occupiedMinutes := make(map[uint]int)
+		for _, l := range lectures {
+			occupied[l.RoomID] += max(int(l.Periods), 1)
+			occupiedMinutes[l.RoomID] += int(l.EndTime - l.StartTime)
+		}
+
+		result := []gin.H{}
+		for _, r := range rooms {
+			used := occupied[r.ID]
+			utilization := percent(used, periods)
+			result = append(result, gin.H{
+				"room_id":             r.ID,
+				"room":                r.Name,
+				"capacity":            r.Capacity,
+				"periods_per_week":    periods,
+				"occupied_periods":    used,
+				"available_periods":   max(periods-used, 0),
+				"occupied_hours":      roundHours(occupiedMinutes[r.ID]),
+				"utilization_percent": utilization,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": result})
+	}
+}
+
+// batchScope returns the batches whose lectures a whole batch attends (itself
+// and its parent) and, in attending, those plus its groups, whose lectures
+// only part of it attends. The batch's Groups must be loaded.
+func batchScope(batch models.Batch) (whole, attending []uint) {
+	whole = []uint{batch.ID}
+	if batch.ParentID != nil {
+		whole = append(whole, *batch.ParentID)
+	}
+	attending = slices.Clone(whole)
+	for _, g := range batch.Groups {
+		attending = append(attending, g.ID)
+	}
+	return whole, attending
+}
+
+// GetCoverageReport compares, per subject of a batch's semester, the periods
+// scheduled each week in the lecture grid with the weekly L-T-P hours the
+// subject requires, counting one contact hour as one period. Lectures of the
+// batch's groups count once per subject, for the group with the most periods,
+// as each student attends only one of them.
+func GetCoverageReport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		batchID, err := strconv.ParseUint(c.Query("batch_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "'batch_id' is required"})
+			return
+		}
+		semester, err := strconv.ParseUint(c.Query("semester"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "'semester' is required"})
+			return
+		}
+
+		var batch models.Batch
+		if err := db.Preload("Groups").First(&batch, batchID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+
+		whole, attending := batchScope(batch)
+
+		var lectures []models.Lecture
+		err = db.Preload("Batches").
+			Where(lectureHasBatchSQL, attending, attending).
+			Where("lectures.semester = ?", semester).
+			Where(activeLectureSQL).
+			Find(&lectures).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch lectures"})
+			return
+		}
+
+		scheduled := make(map[uint]int)
+		perGroup := make(map[uint]map[uint]int)
+		for _, l := range lectures {
+			periods := max(int(l.Periods), 1)
+			ids := lectureBatchIDs(l)
+			if slices.ContainsFunc(ids, func(id uint) bool { return slices.Contains(whole, id) }) {
+				scheduled[l.SubjectID] += periods
+				continue
+			}
+			if perGroup[l.SubjectID] == nil {
+				perGroup[l.SubjectID] = make(map[uint]int)
+			}
+			for _, id := range ids {
+				if slices.Contains(attending, id) {
+					perGroup[l.SubjectID][id] += periods
+				}
+			}
+		}
+		for subjectID, groups := range perGroup {
+			most := 0
+			for _, periods := range groups {
+				most = max(most, periods)
+			}
+			scheduled[subjectID] += most
+		}
+
+		scheduledIDs := make([]uint, 0, len(scheduled))
+		for id := range scheduled {
+			scheduledIDs = append(scheduledIDs, id)
+		}
+		query := db.Where("course_id = ? AND semester = ?", batch.CourseID, semester)
+		if len(scheduledIDs) > 0 {
+			query = query.Or("id IN ?", scheduledIDs)
+		}
+		var subjects []models.Subject
+		if err := query.Order("code").Find(&subjects).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch subjects"})
+			return
+		}
+
+		result := []gin.H{}
+		counts := map[string]int{"ok": 0, "under": 0, "over": 0, "unspecified": 0}
+		for _, s := range subjects {
+			required := int(s.LectureHours + s.TutorialHours + s.PracticalHours)
+			got := scheduled[s.ID]
+
+			status := "ok"
+			switch {
+			case required == 0:
+				status = "unspecified"
+			case got < required:
+				status = "under"
+			case got > required:
+				status = "over"
+			}
+			counts[status]++
+
+			result = append(result, gin.H{
+				"subject_id":        s.ID,
+				"code":              s.Code,
+				"subject":           s.Name,
+				"semester":          s.Semester,
+				"credits":           s.Credits,
+				"lecture_hours":     s.LectureHours,
+				"tutorial_hours":    s.TutorialHours,
+				"practical_hours":   s.PracticalHours,
+				"required_periods":  required,
+				"scheduled_periods": got,
+				"difference":        got - required,
+				"status":            status,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"batch_id": batch.ID,
+			"semester": semester,
+			"summary":  counts,
+			"data":     result,
+		})
+	}
+}
+
+// attendanceTally counts a student's attendance in held sessions. Excused
+// sessions and sessions without a record don't count towards the percentage.
+type attendanceTally struct {
+	Held     int     `json:"held"`
+	Present  int     `json:"present"`
+	Late     int     `json:"late"`
+	Absent   int     `json:"absent"`
+	Excused  int     `json:"excused"`
+	Unmarked int     `json:"unmarked"`
+	Percent  float64 `json:"percent"`
+}
+
+func (t *attendanceTally) add(status string) {
+	t.Held++
+	switch status {
+	case "present":
+		t.Present++
+	case "late":
+		t.Late++
+	case "absent":
+		t.Absent++
+	case "excused":
+		t.Excused++
+	default:
+		t.Unmarked++
+	}
+	t.Percent = percent(t.Present+t.Late, t.Present+t.Late+t.Absent)
+}
+
+// counted is the number of sessions the percentage is based on.
+func (t attendanceTally) counted() int {
+	return t.Present + t.Late + t.Absent
+}
+
+type subjectAttendance struct {
+	SubjectID uint   `json:"subject_id"`
+	Code      string `json:"code"`
+	Subject   string `json:"subject"`
+	attendanceTally
+}
+
+type studentAttendance struct {
+	StudentID  uint                `json:"student_id"`
+	RollNumber string              `json:"roll_number"`
+	Name       string              `json:"name"`
+	Subjects   []subjectAttendance `json:"subjects"`
+	Overall    attendanceTally     `json:"overall"`
+}
+
+// reportStudents loads the students a report is about, given by student_id
+// or batch_id (which also matches a sub-group). It writes a 400 or 404
+// response and returns ok=false when neither identifies any.
+func reportStudents(c *gin.Context, db *gorm.DB) (students []models.Student, ok bool) {
+	query := db.Preload("Electives").Order("roll_number")
+	if studentID := c.Query("student_id"); studentID != "" {
+		query = query.Where("id = ?", studentID)
+	} else if batchID := c.Query("batch_id"); batchID != "" {
+		query = query.Where("batch_id = ? OR group_id = ?", batchID, batchID)
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'student_id' or 'batch_id' is required"})
+		return nil, false
+	}
+
+	if err := query.Find(&students).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch students"})
+		return nil, false
+	}
+	if len(students) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no students found"})
+		return nil, false
+	}
+	return students, true
+}
+
+// computeAttendance tallies the attendance of students per subject and
+// overall over the held sessions between from and to, those of closed terms
+// only if archived. Cancelled or open sessions don't count. The students'
+// Electives must be loaded.
+func computeAttendance(db *gorm.DB, students []models.Student, from, to time.Time, subjectID string, archived bool) ([]studentAttendance, error) {
+	batchIDs := []uint{}
+	for _, st := range students {
+		batchIDs = append(batchIDs, st.BatchID)
+		if st.GroupID != nil {
+			batchIDs = append(batchIDs, *st.GroupID)
+		}
+	}
+
+	lectureQuery := db.Preload("Batches").Preload("Subject").Where(lectureHasBatchSQL, batchIDs, batchIDs)
+	if subjectID != "" {
+		lectureQuery = lectureQuery.Where("lectures.subject_id = ?", subjectID)
+	}
+	var lectures []models.Lecture
+	if err := lectureQuery.Find(&lectures).Error; err != nil {
+		return nil, err
+	}
+	lectureByID := make(map[uint]models.Lecture, len(lectures))
+	lectureIDs := make([]uint, 0, len(lectures))
+	for _, l := range lectures {
+		lectureByID[l.ID] = l
+		lectureIDs = append(lectureIDs, l.ID)
+	}
+
+	var sessions []models.Session
+	err := db.Where("lecture_id IN ?", lectureIDs).
+		Where("status = ?", models.SessionHeld).
+		Where("archived = ?", archived).
+		Where("date BETWEEN ? AND ?", from, to).
+		Order("date").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	sessionIDs := make([]uint, 0, len(sessions))
+	for _, s := range sessions {
+		sessionIDs = append(sessionIDs, s.ID)
+	}
+
+	var records []models.Attendance
+	if err := db.Where("session_id IN ?", sessionIDs).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	type key struct{ session, student uint }
+	status := make(map[key]string, len(records))
+	for _, r := range records {
+		status[key{r.SessionID, r.StudentID}] = r.Status
+	}
+
+	result := make([]studentAttendance, 0, len(students))
+	for _, st := range students {
+		row := studentAttendance{StudentID: st.ID, RollNumber: st.RollNumber, Name: st.Name, Subjects: []subjectAttendance{}}
+		bySubject := make(map[uint]*attendanceTally)
+		for _, s := range sessions {
+			l := lectureByID[s.LectureID]
+			if !onRoll(st, l) {
+				continue
+			}
+			if bySubject[l.SubjectID] == nil {
+				bySubject[l.SubjectID] = &attendanceTally{}
+			}
+			bySubject[l.SubjectID].add(status[key{s.ID, st.ID}])
+			row.Overall.add(status[key{s.ID, st.ID}])
+		}
+
+		for _, l := range lectures {
+			tally, ok := bySubject[l.SubjectID]
+			if !ok {
+				continue
+			}
+			row.Subjects = append(row.Subjects, subjectAttendance{
+				SubjectID:       l.SubjectID,
+				Code:            l.Subject.Code,
+				Subject:         l.Subject.Name,
+				attendanceTally: *tally,
+			})
+			delete(bySubject, l.SubjectID)
+		}
+		slices.SortFunc(row.Subjects, func(a, b subjectAttendance) int { return strings.Compare(a.Code, b.Code) })
+		result = append(result, row)
+	}
+	return result, nil
+}
+
+// attendanceRow formats a tally for CSV and PDF exports.
+func attendanceRow(prefix []string, t attendanceTally) []string {
+	return append(prefix,
+		strconv.Itoa(t.Held),
+		strconv.Itoa(t.Present),
+		strconv.Itoa(t.Late),
+		strconv.Itoa(t.Absent),
+		strconv.Itoa(t.Excused),
+		strconv.Itoa(t.Unmarked),
+		strconv.FormatFloat(t.Percent, 'f', 2, 64),
+	)
+}
+
+var attendanceColumns = []string{"held", "present", "late", "absent", "excused", "unmarked", "percent"}
+
+// GetAttendanceReport reports each student's attendance percentage per subject
+// and overall over a date range, for a student or a whole batch. Pass
+// format=csv or format=pdf to download it.
+func GetAttendanceReport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		from, to, ok := parseDateRange(c)
+		if !ok {
+			return
+		}
+		archived, ok := parseArchived(c)
+		if !ok {
+			return
+		}
+		students, ok := reportStudents(c, db)
+		if !ok {
+			return
+		}
+
+		report, err := computeAttendance(db, students, from, to, c.Query("subject_id"), archived)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute attendance"})
+			return
+		}
+
+		rows := [][]string{}
+		for _, st := range report {
+			for _, s := range st.Subjects {
+				rows = append(rows, attendanceRow([]string{st.RollNumber, st.Name, s.Code}, s.attendanceTally))
+			}
+			rows = append(rows, attendanceRow([]string{st.RollNumber, st.Name, "OVERALL"}, st.Overall))
+		}
+		title := fmt.Sprintf("Attendance %s to %s", from.Format(dateLayout), to.Format(dateLayout))
+		header := append([]string{"roll_number", "name", "subject"}, attendanceColumns...)
+		if exportTable(c, "attendance", title, header, rows) {
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"from": from.Format(dateLayout),
+			"to":   to.Format(dateLayout),
+			"data": report,
+		})
+	}
+}
+
+// defaultAttendanceThreshold is the minimum attendance percentage a student
+// needs, unless a report asks for another threshold.
+const defaultAttendanceThreshold = 75.0
+
+// GetAttendanceShortageReport lists the students of a batch whose attendance
+// over a date range is below the threshold (75% by default), overall or in
+// any subject.
+func GetAttendanceShortageReport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Query("batch_id") == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "'batch_id' is required"})
+			return
+		}
+		threshold := defaultAttendanceThreshold
+		if s := c.Query("threshold"); s != "" {
+			t, err := strconv.ParseFloat(s, 64)
+			if err != nil || t < 0 || t > 100 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "'threshold' must be a percentage between 0 and 100"})
+				return
+			}
+			threshold = t
+		}
+		from, to, ok := parseDateRange(c)
+		if !ok {
+			return
+		}
+		archived, ok := parseArchived(c)
+		if !ok {
+			return
+		}
+		students, ok := reportStudents(c, db)
+		if !ok {
+			return
+		}
+
+		report, err := computeAttendance(db, students, from, to, "", archived)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute attendance"})
+			return
+		}
+
+		short := func(t attendanceTally) bool { return t.counted() > 0 && t.Percent < threshold }
+		result := []gin.H{}
+		rows := [][]string{}
+		for _, st := range report {
+			subjects := []subjectAttendance{}
+			for _, s := range st.Subjects {
+				if short(s.attendanceTally) {
+					subjects = append(subjects, s)
+					rows = append(rows, attendanceRow([]string{st.RollNumber, st.Name, s.Code}, s.attendanceTally))
+				}
+			}
+			if !short(st.Overall) && len(subjects) == 0 {
+				continue
+			}
+			rows = append(rows, attendanceRow([]string{st.RollNumber, st.Name, "OVERALL"}, st.Overall))
+			result = append(result, gin.H{
+				"student_id":     st.StudentID,
+				"roll_number":    st.RollNumber,
+				"name":           st.Name,
+				"overall":        st.Overall,
+				"overall_short":  short(st.Overall),
+				"short_subjects": subjects,
+			})
+		}
+
+		title := fmt.Sprintf("Attendance below %.0f%% from %s to %s", threshold, from.Format(dateLayout), to.Format(dateLayout))
+		header := append([]string{"roll_number", "name", "subject"}, attendanceColumns...)
+		if exportTable(c, "attendance-shortage", title, header, rows) {
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"from":      from.Format(dateLayout),
+			"to":        to.Format(dateLayout),
+			"threshold": threshold,
+			"data":      result,
+		})
+	}
+}
+
+// cancellationCount is a number of cancelled sessions split by reason.
+type cancellationCount struct {
+	Total   int            `json:"total"`
+	Reasons map[string]int `json:"reasons"`
+}
+
+func (c *cancellationCount) add(reason string) {
+	if c.Reasons == nil {
+		c.Reasons = make(map[string]int)
+	}
+	c.Total++
+	c.Reasons[reason]++
+}
+
+// GetCancellationReport counts the sessions cancelled within a date range by
+// reason, by faculty, by subject and per week, month or semester (group_by). Sessions
+// cancelled before reasons were recorded count as "unspecified". Sessions of
+// closed terms are only counted with archived=true.
+func GetCancellationReport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		from, to, ok := parseDateRange(c)
+		if !ok {
+			return
+		}
+		groupBy := c.DefaultQuery("group_by", "month")
+		if !slices.Contains([]string{"week", "month", "semester"}, groupBy) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "'group_by' must be week, month or semester"})
+			return
+		}
+		archived, ok := parseArchived(c)
+		if !ok {
+			return
+		}
+
+		query := db.Preload("Lecture.Faculty").Preload("Lecture.Subject").
+			Joins("JOIN lectures ON lectures.id = sessions.lecture_id").
+			Where("sessions.status = ?", models.SessionCancelled).
+			Where("sessions.archived = ?", archived).
+			Where("sessions.date BETWEEN ? AND ?", from, to).
+			Order("sessions.date")
+		if facultyID := c.Query("faculty_id"); facultyID != "" {
+			query = query.Where("lectures.faculty_id = ?", facultyID)
+		}
+		if subjectID := c.Query("subject_id"); subjectID != "" {
+			query = query.Where("lectures.subject_id = ?", subjectID)
+		}
+		switch reason := c.Query("reason"); {
+		case reason == "":
+		case reason == "unspecified":
+			query = query.Where("COALESCE(sessions.cancel_reason, '') = ''")
+		case slices.Contains(models.CancelReasons, models.CancelReason(reason)):
+			query = query.Where("sessions.cancel_reason = ?", reason)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid reason", "allowed": append(models.CancelReasons[:len(models.CancelReasons):len(models.CancelReasons)], "unspecified")})
+			return
+		}
+
+		var sessions []models.Session
+		if err := query.Find(&sessions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sessions"})
+			return
+		}
+
+		total := cancellationCount{Reasons: make(map[string]int)}
+		byFaculty := make(map[uint]*cancellationCount)
+		bySubject := make(map[uint]*cancellationCount)
+		byPeriod := make(map[string]*cancellationCount)
+		faculties := []models.Faculty{}
+		subjects := []models.Subject{}
+		periods := []string{}
+		for _, s := range sessions {
+			reason := string(s.CancelReason)
+			if reason == "" {
+				reason = "unspecified"
+			}
+			total.add(reason)
+
+			l := s.Lecture
+			if byFaculty[l.FacultyID] == nil {
+				byFaculty[l.FacultyID] = &cancellationCount{}
+				faculties = append(faculties, l.Faculty)
+			}
+			byFaculty[l.FacultyID].add(reason)
+
+			if bySubject[l.SubjectID] == nil {
+				bySubject[l.SubjectID] = &cancellationCount{}
+				subjects = append(subjects, l.Subject)
+			}
+			bySubject[l.SubjectID].add(reason)
+
+			period := periodKey(s.Date, groupBy)
+			if byPeriod[period] == nil {
+				byPeriod[period] = &cancellationCount{}
+				periods = append(periods, period)
+			}
+			byPeriod[period].add(reason)
+		}
+
+		// Most cancellations first
+		slices.SortStableFunc(faculties, func(a, b models.Faculty) int {
+			return byFaculty[b.ID].Total - byFaculty[a.ID].Total
+		})
+		slices.SortStableFunc(subjects, func(a, b models.Subject) int {
+			return bySubject[b.ID].Total - bySubject[a.ID].Total
+		})
+
+		facultyRows := []gin.H{}
+		for _, f := range faculties {
+			facultyRows = append(facultyRows, gin.H{"faculty_id": f.ID, "faculty": f.Name, "cancelled": byFaculty[f.ID]})
+		}
+		subjectRows := []gin.H{}
+		for _, s := range subjects {
+			subjectRows = append(subjectRows, gin.H{"subject_id": s.ID, "code": s.Code, "subject": s.Name, "cancelled": bySubject[s.ID]})
+		}
+		periodRows := []gin.H{}
+		for _, p := range periods {
+			periodRows = append(periodRows, gin.H{"period": p, "cancelled": byPeriod[p]})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"from":       from.Format(dateLayout),
+			"to":         to.Format(dateLayout),
+			"total":      total,
+			"by_faculty": facultyRows,
+			"by_subject": subjectRows,
+			"timeline":   periodRows,
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// semestersPerYear turns a course's Course_Duration in years into semesters.
+const semestersPerYear = 2
+
+// errRolloverRefused aborts a rollover of a term that was already closed or
+// of batches whose semester doesn't fit the term.
+var errRolloverRefused = errors.New("rollover refused")
+
+// parseTerm reads a term such as 2026-odd: the year its academic year starts
+// in and whether its batches are in odd or even semesters.
+func parseTerm(term string) (odd bool, ok bool) {
+	year, half, found := strings.Cut(term, "-")
+	if _, err := strconv.Atoi(year); !found || len(year) != 4 || err != nil {
+		return false, false
+	}
+	return half == "odd", half == "odd" || half == "even"
+}
+
+// rolloverSummary is what the semester rollover did to one course.
+type rolloverSummary struct {
+	CourseID         uint           `json:"course_id"`
+	Course           string         `json:"course"`
+	Term             string         `json:"term"`
+	Promoted         []uint         `json:"promoted"`
+	Graduated        []uint         `json:"graduated"`
+	Created          []models.Batch `json:"created"`
+	ArchivedLectures int64          `json:"archived_lectures"`
+	ArchivedSessions int64          `json:"archived_sessions"`
+	DroppedSessions  int64          `json:"dropped_sessions"`
+}
+
+// archiveTerm archives the lectures of a batch's timetable for a semester
+// together with their sessions. Open sessions from today on are dropped
+// rather than archived.
+func archiveTerm(tx *gorm.DB, batchIDs []uint, semester uint, summary *rolloverSummary) error {
+	var lectureIDs []uint
+	err := tx.Model(&models.Lecture{}).
+		Where(activeLectureSQL).
+		Where("lectures.batch_id IN ? AND lectures.semester = ?", batchIDs, semester).
+		Pluck("id", &lectureIDs).Error
+	if err != nil || len(lectureIDs) == 0 {
+		return err
+	}
+
+	for _, id := range lectureIDs {
+		n, err := dropOpenSessions(tx, id)
+		if err != nil {
+			return err
+		}
+		summary.DroppedSessions += n
+	}
+
+	res := tx.Model(&models.Lecture{}).Where("id IN ?", lectureIDs).Update("archived", true)
+	if res.Error != nil {
+		return res.Error
+	}
+	summary.ArchivedLectures += res.RowsAffected
+
+	res = tx.Model(&models.Session{}).Where("lecture_id IN ?", lectureIDs).Update("archived", true)
+	if res.Error != nil {
+		return res.Error
+	}
+	summary.ArchivedSessions += res.RowsAffected
+	return nil
+}
+
+// rolloverCourse closes a term of a course's batches: it archives their
+// lectures for their current semester, moves them up a semester and graduates
+// those that were in the course's final semester. The term must not have been
+// closed before, and every batch must be in a semester of the term's half
+// (odd or even) without lectures beyond the coming semester. When first-year
+// batches move on to their second year, a first-year intake for year is
+// created with the sections given or, without them, the sections, groups and
+// strengths of the first-year batches.
+func rolloverCourse(tx *gorm.DB, course models.Course, term string, year int, sections []string, closedBy uint) (rolloverSummary, error) {
+	summary := rolloverSummary{
+		CourseID:  course.ID,
+		Course:    course.Name,
+		Term:      term,
+		Promoted:  []uint{},
+		Graduated: []uint{},
+		Created:   []models.Batch{},
+	}
+	final := uint(course.Course_Duration) * semestersPerYear
+	odd, _ := parseTerm(term)
+
+	var closed int64
+	if err := tx.Model(&models.ClosedTerm{}).Where("course_id = ? AND term = ?", course.ID, term).Count(&closed).Error; err != nil {
+		return summary, err
+	}
+	if closed > 0 {
+		return summary, fmt.Errorf("%w: %s already closed term %s", errRolloverRefused, course.Name, term)
+	}
+
+	var batches []models.Batch
+	err := tx.Preload("Groups").
+		Where("course_id = ? AND parent_id IS NULL AND NOT graduated", course.ID).
+		Order("current_semester, section").
+		Find(&batches).Error
+	if err != nil {
+		return summary, err
+	}
+
+	scopes := make([][]uint, len(batches))
+	for i, b := range batches {
+		scopes[i] = []uint{b.ID}
+		for _, g := range b.Groups {
+			scopes[i] = append(scopes[i], g.ID)
+		}
+
+		if (b.CurrentSemester%2 == 1) != odd {
+			return summary, fmt.Errorf("%w: batch %d (%d %s) is in semester %d, which is not in term %s",
+				errRolloverRefused, b.ID, b.Year, b.Section, b.CurrentSemester, term)
+		}
+		var latest uint
+		err := tx.Model(&models.Lecture{}).
+			Where(activeLectureSQL).
+			Where("lectures.batch_id IN ?", scopes[i]).
+			Select("COALESCE(MAX(lectures.semester), 0)").
+			Scan(&latest).Error
+		if err != nil {
+			return summary, err
+		}
+		// Lectures for the coming semester may already be planned.
+		if latest > b.CurrentSemester+1 {
+			return summary, fmt.Errorf("%w: batch %d (%d %s) has lectures for semester %d but is in semester %d, set its current_semester first",
+				errRolloverRefused, b.ID, b.Year, b.Section, latest, b.CurrentSemester)
+		}
+	}
+
+	for i, b := range batches {
+		if err := archiveTerm(tx, scopes[i], b.CurrentSemester, &summary); err != nil {
+			return summary, err
+		}
+
+		update := map[string]any{"current_semester": b.CurrentSemester + 1}
+		if final > 0 && b.CurrentSemester >= final {
+			update = map[string]any{"graduated": true}
+			summary.Graduated = append(summary.Graduated, b.ID)
+		} else {
+			summary.Promoted = append(summary.Promoted, b.ID)
+		}
+		if err := tx.Model(&models.Batch{}).Where("id IN ?", scopes[i]).Updates(update).Error; err != nil {
+			return summary, err
+		}
+	}
+
+	closedTerm := models.ClosedTerm{CourseID: course.ID, Term: term, ClosedByID: &closedBy, ClosedAt: time.Now()}
+	if err := tx.Omit(clause.Associations).Create(&closedTerm).Error; err != nil {
+		return summary, err
+	}
+
+	firstYear := []models.Batch{}
+	for _, b := range batches {
+		if b.CurrentSemester == semestersPerYear {
+			firstYear = append(firstYear, b)
+		}
+	}
+	if len(sections) == 0 && len(firstYear) == 0 {
+		return summary, nil
+	}
+
+	intake := []models.Batch{}
+	if len(sections) > 0 {
+		for _, section := range sections {
+			if section = strings.TrimSpace(section); section != "" {
+				intake = append(intake, models.Batch{Section: section})
+			}
+		}
+	} else {
+		for _, b := range firstYear {
+			batch := models.Batch{Section: b.Section, Strength: b.Strength}
+			for _, g := range b.Groups {
+				batch.Groups = append(batch.Groups, models.Batch{Section: g.Section, Strength: g.Strength})
+			}
+			intake = append(intake, batch)
+		}
+	}
+
+	for _, b := range intake {
+		groups := b.Groups
+		b.Year, b.CourseID, b.CurrentSemester, b.Groups = year, course.ID, 1, nil
+		if err := tx.Omit(clause.Associations).Create(&b).Error; err != nil {
+			return summary, err
+		}
+		for _, g := range groups {
+			g.Year, g.CourseID, g.CurrentSemester, g.ParentID = year, course.ID, 1, &b.ID
+			if err := tx.Omit(clause.Associations).Create(&g).Error; err != nil {
+				return summary, err
+			}
+			b.Groups = append(b.Groups, g)
+		}
+		summary.Created = append(summary.Created, b)
+	}
+	return summary, nil
+}
+
+// Rollover closes a term for every course, or those in course_ids, in one
+// transaction; see rolloverCourse. With dry_run nothing is written.
+func Rollover(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Term      string            `json:"term" binding:"required"` // term closed, e.g. 2026-odd
+			CourseIDs []uint            `json:"course_ids"`
+			Year      int               `json:"year"`     // admission year of new intakes
+			Sections  map[uint][]string `json:"sections"` // sections of a course's new intake
+			DryRun    bool              `json:"dry_run"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if _, ok := parseTerm(input.Term); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "'term' must be a year and odd or even, e.g. 2026-odd"})
+			return
+		}
+		if input.Year == 0 {
+			input.Year = time.Now().Year()
+		}
+
+		user, err := currentUser(c, db)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		query := db.Order("id")
+		if len(input.CourseIDs) > 0 {
+			query = query.Where("id IN ?", input.CourseIDs)
+		}
+		var courses []models.Course
+		if err := query.Find(&courses).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch courses"})
+			return
+		}
+		if len(courses) != len(input.CourseIDs) && len(input.CourseIDs) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "some courses were not found"})
+			return
+		}
+
+		summaries := []rolloverSummary{}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			for _, course := range courses {
+				summary, err := rolloverCourse(tx, course, input.Term, input.Year, input.Sections[course.ID], user.ID)
+				if err != nil {
+					return err
+				}
+				summaries = append(summaries, summary)
+			}
+			if input.DryRun {
+				return errDryRun
+			}
+			return nil
+		})
+		if errors.Is(err, errRolloverRefused) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil && !errors.Is(err, errDryRun) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if input.DryRun {
+			// The new batches were rolled back.
+			for _, summary := range summaries {
+				for i := range summary.Created {
+					summary.Created[i].ID = 0
+					for j := range summary.Created[i].Groups {
+						summary.Created[i].Groups[j].ID, summary.Created[i].Groups[j].ParentID = 0, nil
+					}
+				}
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"data": summaries, "dry_run": input.DryRun})
+	}
+}
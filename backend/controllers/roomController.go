@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// roomQuery describes the rooms wanted by freeRooms.
+type roomQuery struct {
+	Day              string
+	Date             *time.Time // check bookings on this date instead of the weekly grid
+	StartTime        models.TimeOfDay
+	EndTime          models.TimeOfDay
+	MinCapacity      int
+	Type             string        // required room type, if any
+	Features         models.TagSet // required features
+	ExcludeLectureID uint          // lecture whose own booking is ignored
+}
+
+// freeRooms returns the rooms matching q that are not booked at its slot,
+// smallest first.
+func freeRooms(db *gorm.DB, q roomQuery) ([]models.Room, error) {
+	busy, err := busyRoomIDs(db, q.Day, q.Date, q.StartTime, q.EndTime, q.ExcludeLectureID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := db.Where("COALESCE(capacity, 0) >= ?", q.MinCapacity).Order("capacity, name")
+	if len(busy) > 0 {
+		query = query.Where("id NOT IN ?", busy)
+	}
+	if q.Type != "" {
+		query = query.Where("type = ?", q.Type)
+	}
+
+	var candidates []models.Room
+	if err := query.Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	rooms := []models.Room{}
+	for _, r := range candidates {
+		if len(r.Features.Missing(q.Features)) == 0 {
+			rooms = append(rooms, r)
+		}
+	}
+	return rooms, nil
+}
+
+// AvailableRooms lists the rooms free between start and end, either in the
+// weekly grid (day=Monday) or on a specific date (date=YYYY-MM-DD), which
+// also considers cancelled and one-off sessions. Rooms can be narrowed down
+// by type and features, or to the ones a subject requires.
+func AvailableRooms(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		day := c.Query("day")
+		dateStr := c.Query("date")
+
+		if (day == "") == (dateStr == "") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "exactly one of 'day' or 'date' is required"})
+			return
+		}
+		start, end, ok := parseTimeRange(c.Query("start"), c.Query("end"))
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "'start' and 'end' must be HH:MM with start before end"})
+			return
+		}
+
+		var date *time.Time
+		if dateStr != "" {
+			d, err := time.Parse(dateLayout, dateStr)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+				return
+			}
+			date, day = &d, d.Weekday().String()
+		} else if !validDayOfWeek(day) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'day' parameter, e.g. Monday"})
+			return
+		}
+
+		minCapacity := 0
+		if s := c.Query("min_capacity"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'min_capacity' parameter"})
+				return
+			}
+			minCapacity = n
+		}
+
+		q := roomQuery{
+			Day:         day,
+			Date:        date,
+			StartTime:   start,
+			EndTime:     end,
+			MinCapacity: minCapacity,
+			Type:        c.Query("type"),
+		}
+		if features := c.Query("features"); features != "" {
+			q.Features = models.NewTagSet(strings.Split(features, ",")...)
+		}
+		if subjectID := c.Query("subject_id"); subjectID != "" {
+			var subject models.Subject
+			if err := db.First(&subject, subjectID).Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "subject not found"})
+				return
+			}
+			if subject.RequiredRoomType != "" {
+				q.Type = subject.RequiredRoomType
+			}
+			q.Features = models.NewTagSet(append(q.Features, subject.RequiredFeatures...)...)
+		}
+
+		rooms, err := freeRooms(db, q)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find free rooms"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"day": day, "start": start, "end": end, "data": rooms})
+	}
+}
@@ -0,0 +1,471 @@
+package controllers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// sessionFacultyID returns who takes a session: the substitute if one was
+// assigned, the lecture's faculty otherwise. Lecture and Substitution must be
+// loaded.
+func sessionFacultyID(s models.Session) uint {
+	if s.Substitution != nil {
+		return s.Substitution.SubstituteFacultyID
+	}
+	return s.Lecture.FacultyID
+}
+
+// sessionSlotConflicts returns what already overlaps session s, taught by
+// facultyID, if it were held on date from start to end in roomID. The
+// session's Lecture.Batches and Lecture.Subject must be loaded.
+func sessionSlotConflicts(db *gorm.DB, s models.Session, facultyID uint, date time.Time, start, end models.TimeOfDay, roomID uint) ([]conflict, error) {
+	batchIDs, err := relatedBatchIDs(db, lectureBatchIDs(s.Lecture))
+	if err != nil {
+		return nil, err
+	}
+	return findSlotConflicts(db, slotQuery{
+		Date:             date,
+		StartTime:        start,
+		EndTime:          end,
+		RoomID:           roomID,
+		FacultyID:        facultyID,
+		BatchIDs:         batchIDs,
+		ElectiveGroupID:  electiveGroupOf(s.Lecture),
+		ExcludeSessionID: s.ID,
+	})
+}
+
+// canManageSession reports whether the current user may record what happened
+// in a session: admins and the faculty taking it.
+func canManageSession(c *gin.Context, db *gorm.DB, s models.Session) bool {
+	if isAdmin(c) {
+		return true
+	}
+	faculty, err := currentFaculty(c, db)
+	return err == nil && faculty.ID == sessionFacultyID(s)
+}
+
+// statusUpdate returns the columns to write for a session status change made
+// by user, recording who made it and when. A reason only applies to
+// cancellations.
+func statusUpdate(status models.SessionStatus, reason models.CancelReason, note string, user *models.User) map[string]any {
+	update := map[string]any{
+		"status":               status,
+		"cancel_reason":        nil,
+		"status_note":          note,
+		"status_changed_by_id": nil,
+		"status_changed_at":    time.Now(),
+	}
+	if status == models.SessionCancelled && reason != "" {
+		update["cancel_reason"] = reason
+	}
+	if user != nil {
+		update["status_changed_by_id"] = user.ID
+	}
+	return update
+}
+
+// manualStatuses are the statuses a session can be set to by hand; leave
+// flags are only set by leave approval.
+var manualStatuses = []models.SessionStatus{models.SessionScheduled, models.SessionHeld, models.SessionCancelled}
+
+// SetSessionStatus changes the status of a session, recording who changed it
+// and when. Cancelling requires a reason code. The faculty taking the session
+// and admins may do so.
+func SetSessionStatus(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Status models.SessionStatus `json:"status"`
+			Reason models.CancelReason  `json:"reason"`
+			Note   string               `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !slices.Contains(manualStatuses, input.Status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be held, cancelled or empty to reopen the session"})
+			return
+		}
+		if input.Status == models.SessionCancelled && input.Reason == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a reason is required to cancel a session", "allowed": models.CancelReasons})
+			return
+		}
+
+		var session models.Session
+		if err := db.Preload("Lecture").Preload("Substitution").First(&session, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		if session.Archived {
+			c.JSON(http.StatusConflict, gin.H{"error": "session is archived"})
+			return
+		}
+		if !canManageSession(c, db, session) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only the faculty taking this session can change its status"})
+			return
+		}
+		if input.Status == models.SessionHeld && session.Date.Format(dateLayout) > time.Now().Format(dateLayout) {
+			c.JSON(http.StatusConflict, gin.H{"error": "session hasn't taken place yet"})
+			return
+		}
+		if session.Status == models.SessionCancelled && input.Status != models.SessionCancelled {
+			var makeup models.Session
+			found := db.Where("makeup_for_id = ?", session.ID).Limit(1).Find(&makeup)
+			if found.Error != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": found.Error.Error()})
+				return
+			}
+			if found.RowsAffected > 0 {
+				c.JSON(http.StatusConflict, gin.H{
+					"error":             "session was rescheduled, delete its make-up session before reopening it",
+					"makeup_session_id": makeup.ID,
+				})
+				return
+			}
+		}
+		if session.Status == models.SessionHeld && input.Status != models.SessionHeld {
+			var recorded int64
+			if err := db.Model(&models.Attendance{}).Where("session_id = ?", session.ID).Count(&recorded).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if recorded > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "attendance was already recorded for this session"})
+				return
+			}
+		}
+
+		user, err := currentUser(c, db)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		if err := db.Model(&session).Updates(statusUpdate(input.Status, input.Reason, input.Note, user)).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := db.Preload("StatusChangedBy").First(&session, session.ID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, session)
+	}
+}
+
+// statusFields are the session fields only SetSessionStatus may change,
+// lower-cased and without underscores.
+var statusFields = []string{"status", "reason", "cancelreason", "statusnote", "note", "statuschangedbyid", "statuschangedat", "archived"}
+
+// UpdateSession updates a session's date, slot or room. Its status can only
+// be changed through SetSessionStatus, which records why and by whom.
+func UpdateSession(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var session models.Session
+		if err := db.Preload("Lecture.Batches").Preload("Lecture.Subject").Preload("Substitution").First(&session, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		if session.Archived {
+			c.JSON(http.StatusConflict, gin.H{"error": "session is archived"})
+			return
+		}
+
+		var fields map[string]any
+		if err := c.ShouldBindBodyWith(&fields, binding.JSON); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		for key := range fields {
+			if slices.Contains(statusFields, strings.ReplaceAll(strings.ToLower(key), "_", "")) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "use PUT /session/:id/status to change the status"})
+				return
+			}
+		}
+
+		kept := session
+		if err := c.ShouldBindBodyWith(&session, binding.JSON); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		session.ID = kept.ID
+		if session.LectureID != kept.LectureID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a session can't move to another lecture"})
+			return
+		}
+		session.Lecture, session.Substitution = kept.Lecture, kept.Substitution
+
+		start, end, roomID := session.Slot()
+		if oldStart, oldEnd, oldRoomID := kept.Slot(); !session.Date.Equal(kept.Date) || start != oldStart || end != oldEnd || roomID != oldRoomID {
+			conflicts, err := sessionSlotConflicts(db, session, sessionFacultyID(session), session.Date, start, end, roomID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check for clashes"})
+				return
+			}
+			if len(conflicts) > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "slot clashes with existing classes", "conflicts": conflicts})
+				return
+			}
+		}
+
+		if err := db.Omit(clause.Associations).Save(&session).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, session)
+	}
+}
+
+// RescheduleSession cancels a session and creates a linked one-off make-up
+// session with its own date, time and room. The make-up slot is checked for
+// clashes against the regular grid and other one-off sessions.
+func RescheduleSession(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Date      string              `json:"date" binding:"required"`
+			StartTime string              `json:"start_time"`
+			EndTime   string              `json:"end_time"`
+			RoomID    uint                `json:"room_id"`
+			Reason    models.CancelReason `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var original models.Session
+		if err := db.Preload("Lecture.Batches").Preload("Lecture.Subject").First(&original, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		if original.Archived {
+			c.JSON(http.StatusConflict, gin.H{"error": "session is archived"})
+			return
+		}
+		if original.Status == models.SessionHeld {
+			c.JSON(http.StatusConflict, gin.H{"error": "session was already held"})
+			return
+		}
+		var existing models.Session
+		found := db.Where("makeup_for_id = ?", original.ID).Limit(1).Find(&existing)
+		if found.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": found.Error.Error()})
+			return
+		}
+		if found.RowsAffected > 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":             "session was already rescheduled",
+				"makeup_session_id": existing.ID,
+			})
+			return
+		}
+
+		date, err := time.Parse(dateLayout, input.Date)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+			return
+		}
+
+		start, end, roomID := original.Slot()
+		if input.StartTime != "" || input.EndTime != "" {
+			var ok bool
+			start, end, ok = parseTimeRange(input.StartTime, input.EndTime)
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "start_time and end_time must be HH:MM with start before end"})
+				return
+			}
+		}
+		if input.RoomID != 0 {
+			roomID = input.RoomID
+			if err := db.First(&models.Room{}, roomID).Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "room not found"})
+				return
+			}
+		}
+
+		facultyID := original.Lecture.FacultyID
+		var sub models.Substitution
+		found = db.Where("session_id = ?", original.ID).Limit(1).Find(&sub)
+		if found.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": found.Error.Error()})
+			return
+		}
+		if found.RowsAffected > 0 {
+			facultyID = sub.SubstituteFacultyID
+		}
+
+		conflicts, err := sessionSlotConflicts(db, original, facultyID, date, start, end, roomID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check for clashes"})
+			return
+		}
+		if len(conflicts) > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "make-up slot clashes with existing classes", "conflicts": conflicts})
+			return
+		}
+
+		// The make-up room has to suit the class as the lecture's room does.
+		slot := original.Lecture
+		slot.DayOfWeek, slot.StartTime, slot.EndTime, slot.RoomID = date.Weekday().String(), start, end, roomID
+		blocking, warnings, err := runLectureChecks(db, &slot, roomChecks)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(blocking) > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "make-up room doesn't suit the class", "issues": blocking})
+			return
+		}
+
+		user, err := currentUser(c, db)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+		reason := input.Reason
+		if reason == "" {
+			reason = models.CancelOther
+		}
+
+		makeup := models.Session{
+			LectureID:   original.LectureID,
+			Date:        date,
+			StartTime:   &start,
+			EndTime:     &end,
+			RoomID:      &roomID,
+			MakeupForID: &original.ID,
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			update := statusUpdate(models.SessionCancelled, reason, "rescheduled", user)
+			if err := tx.Model(&original).Updates(update).Error; err != nil {
+				return err
+			}
+			return tx.Omit("Lecture", "Room", "MakeupFor", "Substitution").Create(&makeup).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"original": original, "makeup": makeup, "warnings": warnings})
+	}
+}
+
+// maxGenerateDays caps how many days a single GenerateSessions call covers.
+const maxGenerateDays = 366
+
+// GenerateSessions creates the regular sessions of every matching lecture for
+// each date in a range, skipping dates that already have one. Sessions whose
+// faculty is on approved leave are flagged as needing substitution or
+// cancellation.
+func GenerateSessions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			From     string `json:"from" binding:"required"`
+			To       string `json:"to" binding:"required"`
+			BatchID  uint   `json:"batch_id"`
+			Semester uint   `json:"semester"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		from, err := time.Parse(dateLayout, input.From)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'from' date, use YYYY-MM-DD"})
+			return
+		}
+		to, err := time.Parse(dateLayout, input.To)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'to' date, use YYYY-MM-DD"})
+			return
+		}
+		if to.Before(from) || to.Sub(from) > maxGenerateDays*24*time.Hour {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "'to' must be after 'from' and within a year of it"})
+			return
+		}
+
+		lectureQuery := db.Model(&models.Lecture{}).Where(activeLectureSQL)
+		if input.BatchID != 0 {
+			ids := []uint{input.BatchID}
+			lectureQuery = lectureQuery.Where(lectureHasBatchSQL, ids, ids)
+		}
+		if input.Semester != 0 {
+			lectureQuery = lectureQuery.Where("semester = ?", input.Semester)
+		}
+		var lectures []models.Lecture
+		if err := lectureQuery.Find(&lectures).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch lectures"})
+			return
+		}
+
+		type key struct {
+			lectureID uint
+			date      string
+		}
+		var existing []models.Session
+		err = db.Where("date BETWEEN ? AND ?", from, to).
+			Where("NOT (" + oneOffSessionSQL + ")").
+			Find(&existing).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sessions"})
+			return
+		}
+		seen := make(map[key]bool, len(existing))
+		for _, s := range existing {
+			seen[key{s.LectureID, s.Date.Format(dateLayout)}] = true
+		}
+
+		leaves, err := approvedLeaves(db, from, to)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch leaves"})
+			return
+		}
+
+		created := []models.Session{}
+		flagged := 0
+		for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+			for _, l := range lectures {
+				if l.DayOfWeek != d.Weekday().String() || seen[key{l.ID, d.Format(dateLayout)}] {
+					continue
+				}
+
+				s := models.Session{LectureID: l.ID, Date: d, Lecture: l}
+				if leaveOn(leaves[l.FacultyID], d) != nil {
+					flag, err := leaveFlag(db, s)
+					if err != nil {
+						c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check substitutes"})
+						return
+					}
+					s.Status = flag
+					flagged++
+				}
+				created = append(created, s)
+			}
+		}
+
+		if len(created) > 0 {
+			if err := db.Omit("Lecture", "Room", "MakeupFor", "Substitution").Create(&created).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"created": len(created),
+			"flagged": flagged,
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// validateStudent checks a student's fields and that its group, if any, is a
+// sub-group of its batch.
+func validateStudent(db *gorm.DB, s *models.Student) error {
+	s.RollNumber = strings.TrimSpace(s.RollNumber)
+	s.Name = strings.TrimSpace(s.Name)
+	if s.RollNumber == "" || s.Name == "" {
+		return errors.New("RollNumber and Name are required")
+	}
+
+	var batch models.Batch
+	if err := db.First(&batch, s.BatchID).Error; err != nil {
+		return fmt.Errorf("batch %d not found", s.BatchID)
+	}
+	if batch.ParentID != nil {
+		return errors.New("BatchID must be a batch, put sub-groups in GroupID")
+	}
+	if s.GroupID != nil {
+		var group models.Batch
+		if err := db.First(&group, *s.GroupID).Error; err != nil {
+			return fmt.Errorf("group %d not found", *s.GroupID)
+		}
+		if group.ParentID == nil || *group.ParentID != batch.ID {
+			return fmt.Errorf("group %d is not a sub-group of batch %d", group.ID, batch.ID)
+		}
+	}
+	return nil
+}
+
+func QueryStudents(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Preload("Group").Order("roll_number")
+
+		if batchID := c.Query("batch_id"); batchID != "" {
+			query = query.Where("batch_id = ? OR group_id = ?", batchID, batchID)
+		}
+		if groupID := c.Query("group_id"); groupID != "" {
+			query = query.Where("group_id = ?", groupID)
+		}
+		if subjectID := c.Query("elective_id"); subjectID != "" {
+			query = query.Where("id IN (SELECT student_id FROM student_electives WHERE subject_id = ?)", subjectID)
+		}
+
+		students := []models.Student{}
+		if err := query.Find(&students).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, students)
+	}
+}
+
+func GetStudent(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var student models.Student
+		if err := db.Preload("Group").Preload("Electives").First(&student, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		c.JSON(http.StatusOK, student)
+	}
+}
+
+func CreateStudent(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var student models.Student
+		if err := c.ShouldBindJSON(&student); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		student.ID = 0
+		if err := validateStudent(db, &student); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := db.Omit(clause.Associations).Create(&student).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, student)
+	}
+}
+
+func UpdateStudent(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var student models.Student
+		if err := db.First(&student, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		id := student.ID
+		if err := c.ShouldBindJSON(&student); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		student.ID = id
+		if err := validateStudent(db, &student); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := db.Omit(clause.Associations).Save(&student).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, student)
+	}
+}
+
+// readStudentCSV parses students from CSV with a header row naming the
+// columns roll_number, name, email, batch_id and group_id, in any order.
+func readStudentCSV(r io.Reader) ([]models.Student, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("CSV file is empty")
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"roll_number", "name", "batch_id"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV is missing the %q column", required)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	students := []models.Student{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		s := models.Student{
+			RollNumber: field(record, "roll_number"),
+			Name:       field(record, "name"),
+			Email:      field(record, "email"),
+		}
+		batchID, err := strconv.ParseUint(field(record, "batch_id"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid batch_id", line)
+		}
+		s.BatchID = uint(batchID)
+		if g := field(record, "group_id"); g != "" {
+			groupID, err := strconv.ParseUint(g, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid group_id", line)
+			}
+			id := uint(groupID)
+			s.GroupID = &id
+		}
+		students = append(students, s)
+	}
+	return students, nil
+}
+
+// ImportStudents creates or updates students in bulk, matched by roll number.
+// It takes a JSON array of students or a CSV upload in the form field "file".
+// Nothing is imported if any row is invalid.
+func ImportStudents(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var students []models.Student
+		if file, err := c.FormFile("file"); err == nil {
+			f, err := file.Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			defer f.Close()
+			if students, err = readStudentCSV(f); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		} else if err := c.ShouldBindJSON(&students); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if len(students) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no students to import"})
+			return
+		}
+
+		rowErrors := []gin.H{}
+		seen := make(map[string]bool, len(students))
+		for i := range students {
+			s := &students[i]
+			s.ID = 0
+			if err := validateStudent(db, s); err != nil {
+				rowErrors = append(rowErrors, gin.H{"row": i + 1, "roll_number": s.RollNumber, "error": err.Error()})
+				continue
+			}
+			if seen[s.RollNumber] {
+				rowErrors = append(rowErrors, gin.H{"row": i + 1, "roll_number": s.RollNumber, "error": "duplicate roll number"})
+			}
+			seen[s.RollNumber] = true
+		}
+		if len(rowErrors) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rows, nothing was imported", "rows": rowErrors})
+			return
+		}
+
+		err := db.Omit(clause.Associations).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "roll_number"}},
+			DoUpdates: clause.AssignmentColumns([]string{"name", "email", "batch_id", "group_id"}),
+		}).Create(&students).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"imported": len(students)})
+	}
+}
+
+// SetStudentElectives replaces the electives a student is enrolled in. Each
+// subject must belong to an elective group of the student's course, and a
+// student takes at most one subject per group.
+func SetStudentElectives(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			SubjectIDs []uint `json:"subject_ids"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var student models.Student
+		if err := db.Preload("Batch").First(&student, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+
+		input.SubjectIDs = slices.Compact(slices.Sorted(slices.Values(input.SubjectIDs)))
+		subjects := []models.Subject{}
+		if len(input.SubjectIDs) > 0 {
+			if err := db.Where("id IN ?", input.SubjectIDs).Find(&subjects).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if len(subjects) != len(input.SubjectIDs) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "subject not found"})
+			return
+		}
+
+		groups := make(map[uint]bool, len(subjects))
+		for _, s := range subjects {
+			if s.ElectiveGroupID == nil || s.CourseID != student.Batch.CourseID {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("subject %s is not an elective of the student's course", s.Code)})
+				return
+			}
+			if groups[*s.ElectiveGroupID] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("subject %s is a second choice in the same elective group", s.Code)})
+				return
+			}
+			groups[*s.ElectiveGroupID] = true
+		}
+
+		if err := db.Model(&student).Association("Electives").Replace(subjects); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"student_id": student.ID, "data": subjects})
+	}
+}
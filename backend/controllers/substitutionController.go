@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// eligibleSubstitutes returns the faculties who teach the subject of the
+// session's lecture, are not on leave that day and are free when the session
+// takes place.
+func eligibleSubstitutes(db *gorm.DB, session models.Session) ([]models.Faculty, error) {
+	lecture := session.Lecture
+	start, end, _ := session.Slot()
+
+	var candidates []models.Faculty
+	err := db.
+		Joins("JOIN faculty_subjects ON faculty_subjects.faculty_id = faculties.id").
+		Where("faculty_subjects.subject_id = ?", lecture.SubjectID).
+		Where("faculties.id <> ?", lecture.FacultyID).
+		Order("faculties.name").
+		Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	leaves, err := approvedLeaves(db, session.Date, session.Date)
+	if err != nil {
+		return nil, err
+	}
+
+	eligible := []models.Faculty{}
+	for _, f := range candidates {
+		if leaveOn(leaves[f.ID], session.Date) != nil {
+			continue
+		}
+		conflicts, err := findSlotConflicts(db, slotQuery{
+			Date:             session.Date,
+			StartTime:        start,
+			EndTime:          end,
+			FacultyID:        f.ID,
+			ExcludeSessionID: session.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(conflicts) == 0 {
+			eligible = append(eligible, f)
+		}
+	}
+	return eligible, nil
+}
+
+func SuggestSubstitutes(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var session models.Session
+		if err := db.Preload("Lecture").First(&session, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+
+		faculties, err := eligibleSubstitutes(db, session)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find substitutes"})
+			return
+		}
+
+		start, end, _ := session.Slot()
+		c.JSON(http.StatusOK, gin.H{
+			"session_id": session.ID,
+			"date":       session.Date.Format(dateLayout),
+			"start_time": start,
+			"end_time":   end,
+			"data":       faculties,
+		})
+	}
+}
+
+func AssignSubstitute(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			SubstituteFacultyID uint   `json:"substitute_faculty_id" binding:"required"`
+			Reason              string `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var session models.Session
+		if err := db.Preload("Lecture").First(&session, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		if session.Archived {
+			c.JSON(http.StatusConflict, gin.H{"error": "session is archived"})
+			return
+		}
+		if session.Status == models.SessionCancelled {
+			c.JSON(http.StatusConflict, gin.H{"error": "session is cancelled"})
+			return
+		}
+
+		eligible, err := eligibleSubstitutes(db, session)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find substitutes"})
+			return
+		}
+		found := false
+		for _, f := range eligible {
+			if f.ID == input.SubstituteFacultyID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "faculty does not teach this subject, is on leave or is not free at this slot",
+			})
+			return
+		}
+
+		user, err := currentUser(c, db)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		var sub models.Substitution
+		err = db.Where("session_id = ?", session.ID).First(&sub).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		sub.SessionID = session.ID
+		sub.OriginalFacultyID = session.Lecture.FacultyID
+		sub.SubstituteFacultyID = input.SubstituteFacultyID
+		sub.Reason = input.Reason
+		sub.ApprovedByID = &user.ID
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Omit("OriginalFaculty", "SubstituteFaculty", "ApprovedBy").Save(&sub).Error; err != nil {
+				return err
+			}
+			// The session is covered now, so a leave flag no longer applies.
+			return tx.Model(&models.Session{}).
+				Where("id = ? AND status IN ?", session.ID, leaveFlags).
+				Update("status", models.SessionScheduled).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := db.Preload("OriginalFaculty").Preload("SubstituteFaculty").First(&sub, sub.ID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, sub)
+	}
+}
+
+func RemoveSubstitute(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var session models.Session
+		if err := db.First(&session, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		if session.Archived {
+			c.JSON(http.StatusConflict, gin.H{"error": "session is archived"})
+			return
+		}
+
+		if err := db.Where("session_id = ?", session.ID).Delete(&models.Substitution{}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+	}
+}
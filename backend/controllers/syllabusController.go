@@ -0,0 +1,242 @@
+package controllers
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetSyllabus returns the units of a subject's syllabus with their topics, in
+// order.
+func GetSyllabus(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var subject models.Subject
+		if err := db.First(&subject, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+
+		units := []models.SyllabusUnit{}
+		err := db.Preload("Topics", func(tx *gorm.DB) *gorm.DB { return tx.Order("number") }).
+			Where("subject_id = ?", subject.ID).
+			Order("number").
+			Find(&units).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"subject_id": subject.ID, "data": units})
+	}
+}
+
+// MarkSessionHeld marks a session held and logs the syllabus topics covered
+// and notes. Only the faculty taking the session and admins may do so.
+func MarkSessionHeld(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			TopicIDs []uint `json:"topic_ids"`
+			Notes    string `json:"notes"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var session models.Session
+		if err := db.Preload("Lecture").Preload("Substitution").First(&session, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		if !canManageSession(c, db, session) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only the faculty taking this session can mark it held"})
+			return
+		}
+		if session.Archived {
+			c.JSON(http.StatusConflict, gin.H{"error": "session is archived"})
+			return
+		}
+		if session.Status == models.SessionCancelled {
+			c.JSON(http.StatusConflict, gin.H{"error": "session is cancelled"})
+			return
+		}
+		if session.Date.Format(dateLayout) > time.Now().Format(dateLayout) {
+			c.JSON(http.StatusConflict, gin.H{"error": "session hasn't taken place yet"})
+			return
+		}
+
+		topics := []models.SyllabusTopic{}
+		if len(input.TopicIDs) > 0 {
+			err := db.Joins("JOIN syllabus_units ON syllabus_units.id = syllabus_topics.unit_id").
+				Where("syllabus_units.subject_id = ?", session.Lecture.SubjectID).
+				Where("syllabus_topics.id IN ?", input.TopicIDs).
+				Find(&topics).Error
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if len(topics) != len(slices.Compact(slices.Sorted(slices.Values(input.TopicIDs)))) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "topic_ids must be topics of the session's subject"})
+				return
+			}
+		}
+
+		user, err := currentUser(c, db)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			update := statusUpdate(models.SessionHeld, "", "", user)
+			update["notes"] = input.Notes
+			err := tx.Model(&session).Updates(update).Error
+			if err != nil {
+				return err
+			}
+			return tx.Model(&session).Association("Topics").Replace(topics)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"session_id": session.ID,
+			"status":     models.SessionHeld,
+			"notes":      input.Notes,
+			"topics":     topics,
+		})
+	}
+}
+
+// GetSyllabusProgressReport reports, per subject taught to a batch, how many
+// syllabus topics its held sessions covered, unit by unit. Sessions of the
+// batch's groups count towards the batch. Sessions of terms closed by the
+// rollover are only counted with archived=true.
+func GetSyllabusProgressReport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		batchID, err := strconv.ParseUint(c.Query("batch_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "'batch_id' is required"})
+			return
+		}
+		archived, ok := parseArchived(c)
+		if !ok {
+			return
+		}
+
+		var batch models.Batch
+		if err := db.Preload("Groups").First(&batch, batchID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		_, attending := batchScope(batch)
+
+		lectureQuery := db.Model(&models.Lecture{}).Where(lectureHasBatchSQL, attending, attending)
+		if subjectID := c.Query("subject_id"); subjectID != "" {
+			lectureQuery = lectureQuery.Where("lectures.subject_id = ?", subjectID)
+		}
+		if semester := c.Query("semester"); semester != "" {
+			lectureQuery = lectureQuery.Where("lectures.semester = ?", semester)
+		}
+		var lectureIDs, subjectIDs []uint
+		if err := lectureQuery.Pluck("lectures.id", &lectureIDs).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch lectures"})
+			return
+		}
+		if err := lectureQuery.Distinct().Pluck("lectures.subject_id", &subjectIDs).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch lectures"})
+			return
+		}
+
+		var subjects []models.Subject
+		if err := db.Where("id IN ?", subjectIDs).Order("code").Find(&subjects).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch subjects"})
+			return
+		}
+
+		var units []models.SyllabusUnit
+		err = db.Preload("Topics", func(tx *gorm.DB) *gorm.DB { return tx.Order("number") }).
+			Where("subject_id IN ?", subjectIDs).
+			Order("number").
+			Find(&units).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch syllabus"})
+			return
+		}
+
+		var held []models.Session
+		err = db.Preload("Topics").Preload("Lecture").
+			Where("lecture_id IN ?", lectureIDs).
+			Where("status = ?", models.SessionHeld).
+			Where("archived = ?", archived).
+			Find(&held).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sessions"})
+			return
+		}
+
+		covered := make(map[uint]bool)
+		heldCount := make(map[uint]int)
+		lastHeld := make(map[uint]time.Time)
+		for _, s := range held {
+			subjectID := s.Lecture.SubjectID
+			heldCount[subjectID]++
+			if s.Date.After(lastHeld[subjectID]) {
+				lastHeld[subjectID] = s.Date
+			}
+			for _, t := range s.Topics {
+				covered[t.ID] = true
+			}
+		}
+
+		result := []gin.H{}
+		for _, s := range subjects {
+			total, done := 0, 0
+			unitRows := []gin.H{}
+			for _, u := range units {
+				if u.SubjectID != s.ID {
+					continue
+				}
+				unitDone := 0
+				for _, t := range u.Topics {
+					if covered[t.ID] {
+						unitDone++
+					}
+				}
+				total += len(u.Topics)
+				done += unitDone
+				unitRows = append(unitRows, gin.H{
+					"unit_id":        u.ID,
+					"number":         u.Number,
+					"title":          u.Title,
+					"total_topics":   len(u.Topics),
+					"covered_topics": unitDone,
+				})
+			}
+
+			entry := gin.H{
+				"subject_id":      s.ID,
+				"code":            s.Code,
+				"subject":         s.Name,
+				"held_sessions":   heldCount[s.ID],
+				"total_topics":    total,
+				"covered_topics":  done,
+				"percent_covered": percent(done, total),
+				"units":           unitRows,
+			}
+			if last, ok := lastHeld[s.ID]; ok {
+				entry["last_held"] = last.Format(dateLayout)
+			}
+			result = append(result, entry)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"batch_id": batch.ID, "data": result})
+	}
+}
@@ -0,0 +1,932 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"tms-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errDraftExists = errors.New("the timetable already has a draft")
+
+var errDraftStale = errors.New("the live timetable changed after this draft was created, delete it and create a new one")
+
+// errDryRun rolls back a transaction that was only run to report its outcome.
+var errDryRun = errors.New("dry run")
+
+// openSessionStatuses are the statuses of sessions that haven't taken place.
+var openSessionStatuses = append([]models.SessionStatus{models.SessionScheduled}, leaveFlags...)
+
+var versionFilters = []queryFilter{
+	idFilter("batch_id", "batch_id"),
+	{param: "semester", parse: parseInt, apply: whereIn("semester")},
+	{param: "status", parse: parseText, apply: whereIn("status")},
+}
+
+// timetableBatchIDs returns a batch and its groups, whose lectures make up
+// the batch's timetable.
+func timetableBatchIDs(db *gorm.DB, batchID uint) ([]uint, error) {
+	var ids []uint
+	err := db.Model(&models.Batch{}).Where("id = ? OR parent_id = ?", batchID, batchID).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// liveLectures returns the lectures of the batches' timetables for a semester
+// that are in the weekly grid.
+func liveLectures(db *gorm.DB, batchIDs []uint, semester uint) ([]models.Lecture, error) {
+	var lectures []models.Lecture
+	err := db.Preload("Batches").
+		Where(activeLectureSQL).
+		Where("lectures.batch_id IN ? AND lectures.semester = ?", batchIDs, semester).
+		Order(dayOrderSQL("lectures.day_of_week")).
+		Order("lectures.start_time").
+		Find(&lectures).Error
+	return lectures, err
+}
+
+// lecturesHash fingerprints live lectures, so that a draft can tell whether
+// they changed after it was copied from them.
+func lecturesHash(lectures []models.Lecture) string {
+	sorted := slices.Clone(lectures)
+	slices.SortFunc(sorted, func(a, b models.Lecture) int { return int(a.ID) - int(b.ID) })
+
+	h := sha256.New()
+	for _, l := range sorted {
+		batchIDs := lectureBatchIDs(l)
+		slices.Sort(batchIDs)
+		fmt.Fprintf(h, "%d %s %d %d %d %d %d %d %v\n",
+			l.ID, l.DayOfWeek, l.StartTime, l.EndTime, l.SubjectID, l.FacultyID, l.BatchID, l.RoomID, batchIDs)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// entryLecture returns the lecture a timetable entry stands for.
+func entryLecture(v models.TimetableVersion, e models.TimetableEntry) models.Lecture {
+	l := models.Lecture{
+		DayOfWeek: e.DayOfWeek,
+		StartTime: e.StartTime,
+		EndTime:   e.EndTime,
+		Periods:   e.Periods,
+		SubjectID: e.SubjectID,
+		FacultyID: e.FacultyID,
+		BatchID:   e.BatchID,
+		Semester:  v.Semester,
+		RoomID:    e.RoomID,
+		Batches:   e.Batches,
+	}
+	if e.LectureID != nil {
+		l.ID = *e.LectureID
+	}
+	return l
+}
+
+// lectureEntry copies a live lecture into a timetable entry.
+func lectureEntry(l models.Lecture) models.TimetableEntry {
+	id := l.ID
+	return models.TimetableEntry{
+		LectureID: &id,
+		DayOfWeek: l.DayOfWeek,
+		StartTime: l.StartTime,
+		EndTime:   l.EndTime,
+		Periods:   l.Periods,
+		SubjectID: l.SubjectID,
+		FacultyID: l.FacultyID,
+		BatchID:   l.BatchID,
+		RoomID:    l.RoomID,
+		Batches:   l.Batches,
+	}
+}
+
+// writeEntry saves a timetable entry together with the combined batches
+// attending it.
+func writeEntry(tx *gorm.DB, e *models.TimetableEntry) error {
+	if err := tx.Omit(clause.Associations).Save(e).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("timetable_entry_id = ?", e.ID).Delete(&models.TimetableEntryBatch{}).Error; err != nil {
+		return err
+	}
+
+	rows := []models.TimetableEntryBatch{}
+	for _, id := range lectureBatchIDs(models.Lecture{BatchID: e.BatchID, Batches: e.Batches}) {
+		if id != e.BatchID {
+			rows = append(rows, models.TimetableEntryBatch{TimetableEntryID: e.ID, BatchID: id})
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.Create(&rows).Error
+}
+
+// checkEntry validates the fields of a draft entry and fills in its Periods.
+// Clashes and the other scheduling rules are checked when the draft is
+// published, against the timetable it would produce.
+func checkEntry(db *gorm.DB, v models.TimetableVersion, e *models.TimetableEntry) ([]lectureIssue, error) {
+	scope, err := timetableBatchIDs(db, v.BatchID)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(scope, e.BatchID) {
+		return []lectureIssue{{
+			Rule:     "fields",
+			Message:  "BatchID must be the version's batch or one of its groups",
+			Blocking: true,
+		}}, nil
+	}
+
+	l := entryLecture(v, *e)
+	issues := []lectureIssue{}
+	for _, check := range []lectureCheck{checkLectureFields, checkPeriodGrid} {
+		found, err := check(db, &l)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, found...)
+		if len(issues) > 0 {
+			break
+		}
+	}
+	e.Periods = l.Periods
+	e.Batches = l.Batches
+	return issues, nil
+}
+
+// dropOpenSessions deletes a lecture's regular sessions from today on that
+// haven't taken place and have nothing recorded against them, so that they
+// can be generated again for its new slot. It returns how many were deleted.
+func dropOpenSessions(tx *gorm.DB, lectureID uint) (int64, error) {
+	res := tx.Where("lecture_id = ? AND date >= ?", lectureID, time.Now().Format(dateLayout)).
+		Where("NOT ("+oneOffSessionSQL+")").
+		Where("COALESCE(status, '') IN ?", openSessionStatuses).
+		Where("NOT (" + sessionRecordsSQL + ")").
+		Delete(&models.Session{})
+	return res.RowsAffected, res.Error
+}
+
+// retireLecture takes a lecture off the timetable. Its open sessions from
+// today on are dropped; it is archived if sessions remain as its history and
+// deleted otherwise. It returns how many sessions were dropped.
+func retireLecture(tx *gorm.DB, id uint) (int64, error) {
+	dropped, err := dropOpenSessions(tx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	var remaining int64
+	if err := tx.Model(&models.Session{}).Where("lecture_id = ?", id).Count(&remaining).Error; err != nil {
+		return 0, err
+	}
+	if remaining > 0 {
+		return dropped, tx.Model(&models.Lecture{}).Where("id = ?", id).Update("archived", true).Error
+	}
+
+	if err := tx.Where("lecture_id = ?", id).Delete(&models.LectureBatch{}).Error; err != nil {
+		return 0, err
+	}
+	return dropped, tx.Delete(&models.Lecture{}, id).Error
+}
+
+// loadVersion loads a timetable version with its entries in weekly order.
+func loadVersion(db *gorm.DB, id any, v *models.TimetableVersion) error {
+	return db.Preload("Batch").
+		Preload("Entries", func(db *gorm.DB) *gorm.DB {
+			return db.Order(dayOrderSQL("day_of_week")).Order("start_time")
+		}).
+		Preload("Entries.Batches").Preload("Entries.Subject").Preload("Entries.Faculty").Preload("Entries.Room").
+		First(v, id).Error
+}
+
+// draftVersion loads a version to be edited. It writes a 404 response, or a
+// 409 one if the version isn't a draft, and returns ok=false otherwise.
+func draftVersion(c *gin.Context, db *gorm.DB, id any) (v models.TimetableVersion, ok bool) {
+	if err := db.First(&v, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return v, false
+	}
+	if v.Status != models.VersionDraft {
+		c.JSON(http.StatusConflict, gin.H{"error": "only drafts can be changed, published versions are read-only"})
+		return v, false
+	}
+	return v, true
+}
+
+// draftOpenFor writes a 409 response and returns true if the timetable of
+// any of the lectures has an open draft and the caller isn't a superadmin.
+// Such a timetable is changed through its draft, since publishing the draft
+// would otherwise overwrite the change.
+func draftOpenFor(c *gin.Context, db *gorm.DB, lectures ...models.Lecture) bool {
+	if c.GetString("role") == "superadmin" {
+		return false
+	}
+	for _, l := range lectures {
+		var drafts []models.TimetableVersion
+		err := db.Where("batch_id IN (?) AND semester = ? AND status = ?",
+			db.Model(&models.Batch{}).Select("COALESCE(parent_id, id)").Where("id = ?", l.BatchID),
+			l.Semester, models.VersionDraft).
+			Limit(1).Find(&drafts).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return true
+		}
+		if len(drafts) > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "the timetable has an open draft, change the draft instead", "draft_id": drafts[0].ID})
+			return true
+		}
+	}
+	return false
+}
+
+func QueryTimetableVersions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query, ok := applyFilters(c, db, db.Order("batch_id, semester, number DESC"), versionFilters)
+		if !ok {
+			return
+		}
+
+		versions := []models.TimetableVersion{}
+		if err := query.Find(&versions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, versions)
+	}
+}
+
+func GetTimetableVersion(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var v models.TimetableVersion
+		if err := loadVersion(db, c.Param("id"), &v); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		c.JSON(http.StatusOK, v)
+	}
+}
+
+// CreateTimetableDraft starts the next version of a batch's timetable for a
+// semester as a draft holding a copy of its live lectures. A timetable has
+// at most one draft at a time.
+func CreateTimetableDraft(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			BatchID  uint   `json:"batch_id" binding:"required"`
+			Semester uint   `json:"semester" binding:"required"`
+			Note     string `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var batch models.Batch
+		if err := db.First(&batch, input.BatchID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "batch not found"})
+			return
+		}
+		if batch.ParentID != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "groups are part of their parent batch's timetable"})
+			return
+		}
+
+		user, err := currentUser(c, db)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+			return
+		}
+
+		v := models.TimetableVersion{
+			BatchID:     batch.ID,
+			Semester:    input.Semester,
+			Status:      models.VersionDraft,
+			Note:        input.Note,
+			CreatedByID: &user.ID,
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var drafts int64
+			err := tx.Model(&models.TimetableVersion{}).
+				Where("batch_id = ? AND semester = ? AND status = ?", v.BatchID, v.Semester, models.VersionDraft).
+				Count(&drafts).Error
+			if err != nil {
+				return err
+			}
+			if drafts > 0 {
+				return errDraftExists
+			}
+
+			err = tx.Model(&models.TimetableVersion{}).
+				Where("batch_id = ? AND semester = ?", v.BatchID, v.Semester).
+				Select("COALESCE(MAX(number), 0) + 1").
+				Scan(&v.Number).Error
+			if err != nil {
+				return err
+			}
+
+			scope, err := timetableBatchIDs(tx, v.BatchID)
+			if err != nil {
+				return err
+			}
+			lectures, err := liveLectures(tx, scope, v.Semester)
+			if err != nil {
+				return err
+			}
+			v.BaseHash = lecturesHash(lectures)
+			if err := tx.Omit(clause.Associations).Create(&v).Error; err != nil {
+				return err
+			}
+			for _, l := range lectures {
+				e := lectureEntry(l)
+				e.VersionID = v.ID
+				if err := writeEntry(tx, &e); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if errors.Is(err, errDraftExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := loadVersion(db, v.ID, &v); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, v)
+	}
+}
+
+// DeleteTimetableDraft discards a draft.
+func DeleteTimetableDraft(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := draftVersion(c, db, c.Param("id"))
+		if !ok {
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			entryIDs := tx.Model(&models.TimetableEntry{}).Select("id").Where("version_id = ?", v.ID)
+			if err := tx.Where("timetable_entry_id IN (?)", entryIDs).Delete(&models.TimetableEntryBatch{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("version_id = ?", v.ID).Delete(&models.TimetableEntry{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&v).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+	}
+}
+
+// saveEntry checks a draft entry and writes it, answering with the saved
+// entry or with the issues it was rejected for.
+func saveEntry(c *gin.Context, db *gorm.DB, v models.TimetableVersion, e *models.TimetableEntry, status int) {
+	issues, err := checkEntry(db, v, e)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to validate entry"})
+		return
+	}
+	if len(issues) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "entry violates scheduling rules", "issues": issues})
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return writeEntry(tx, e)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(status, gin.H{"data": e})
+}
+
+// AddTimetableEntry adds a lecture to a draft.
+func AddTimetableEntry(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := draftVersion(c, db, c.Param("id"))
+		if !ok {
+			return
+		}
+
+		var entry models.TimetableEntry
+		if err := c.ShouldBindJSON(&entry); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		entry.ID = 0
+		entry.VersionID = v.ID
+		entry.LectureID = nil
+		saveEntry(c, db, v, &entry, http.StatusCreated)
+	}
+}
+
+// UpdateTimetableEntry changes a lecture of a draft. It stays linked to the
+// live lecture it was copied from, so publishing moves that lecture.
+func UpdateTimetableEntry(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var entry models.TimetableEntry
+		if err := db.Preload("Batches").First(&entry, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		v, ok := draftVersion(c, db, entry.VersionID)
+		if !ok {
+			return
+		}
+
+		id, lectureID := entry.ID, entry.LectureID
+		if err := c.ShouldBindJSON(&entry); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		entry.ID, entry.VersionID, entry.LectureID = id, v.ID, lectureID
+		saveEntry(c, db, v, &entry, http.StatusOK)
+	}
+}
+
+// DeleteTimetableEntry removes a lecture from a draft.
+func DeleteTimetableEntry(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var entry models.TimetableEntry
+		if err := db.First(&entry, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		if _, ok := draftVersion(c, db, entry.VersionID); !ok {
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("timetable_entry_id = ?", entry.ID).Delete(&models.TimetableEntryBatch{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&entry).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+	}
+}
+
+// entryIssues are the lectureIssues found for one entry of a version.
+type entryIssues struct {
+	EntryID uint           `json:"entry_id"`
+	Issues  []lectureIssue `json:"issues"`
+}
+
+// PublishTimetableVersion makes a draft the live timetable in one
+// transaction: live lectures the draft dropped are retired, its entries are
+// written to the weekly grid and checked against all scheduling rules, and
+// the previously published version is superseded. Nothing changes if an
+// entry breaks a blocking rule.
+func PublishTimetableVersion(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := draftVersion(c, db, c.Param("id"))
+		if !ok {
+			return
+		}
+
+		user, err := currentUser(c, db)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+			return
+		}
+
+		blocking, warnings := []entryIssues{}, []entryIssues{}
+		var retired int
+		var dropped int64
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var entries []models.TimetableEntry
+			if err := tx.Preload("Batches").Where("version_id = ?", v.ID).Find(&entries).Error; err != nil {
+				return err
+			}
+
+			scope, err := timetableBatchIDs(tx, v.BatchID)
+			if err != nil {
+				return err
+			}
+			live, err := liveLectures(tx, scope, v.Semester)
+			if err != nil {
+				return err
+			}
+			// Entries would overwrite or retire lectures changed live since.
+			if lecturesHash(live) != v.BaseHash {
+				return errDraftStale
+			}
+			liveByID := make(map[uint]models.Lecture, len(live))
+			for _, l := range live {
+				liveByID[l.ID] = l
+			}
+
+			kept := make(map[uint]bool, len(entries))
+			for _, e := range entries {
+				if e.LectureID != nil {
+					kept[*e.LectureID] = true
+				}
+			}
+			for _, l := range live {
+				if kept[l.ID] {
+					continue
+				}
+				n, err := retireLecture(tx, l.ID)
+				if err != nil {
+					return err
+				}
+				retired++
+				dropped += n
+			}
+
+			lectures := make([]models.Lecture, len(entries))
+			for i, e := range entries {
+				l := entryLecture(v, e)
+				if old, ok := liveByID[l.ID]; !ok {
+					// Added to the draft.
+					l.ID = 0
+				} else if old.DayOfWeek != l.DayOfWeek {
+					n, err := dropOpenSessions(tx, l.ID)
+					if err != nil {
+						return err
+					}
+					dropped += n
+				}
+				if err := writeLecture(tx, &l); err != nil {
+					return err
+				}
+				if err := tx.Model(&entries[i]).Update("lecture_id", l.ID).Error; err != nil {
+					return err
+				}
+				lectures[i] = l
+			}
+
+			// Check once every entry is in place so they are checked against each other.
+			for i := range lectures {
+				b, w, err := validateLecture(tx, &lectures[i])
+				if err != nil {
+					return err
+				}
+				if len(b) > 0 {
+					blocking = append(blocking, entryIssues{EntryID: entries[i].ID, Issues: b})
+				}
+				if len(w) > 0 {
+					warnings = append(warnings, entryIssues{EntryID: entries[i].ID, Issues: w})
+				}
+			}
+			if len(blocking) > 0 {
+				return errLectureRejected
+			}
+
+			err = tx.Model(&models.TimetableVersion{}).
+				Where("batch_id = ? AND semester = ? AND status = ?", v.BatchID, v.Semester, models.VersionPublished).
+				Update("status", models.VersionSuperseded).Error
+			if err != nil {
+				return err
+			}
+			return tx.Model(&v).Updates(map[string]any{
+				"status":          models.VersionPublished,
+				"published_by_id": user.ID,
+				"published_at":    time.Now(),
+			}).Error
+		})
+		if errors.Is(err, errDraftStale) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if len(blocking) > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "timetable violates scheduling rules", "issues": blocking})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := loadVersion(db, v.ID, &v); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"data":             v,
+			"warnings":         warnings,
+			"retired_lectures": retired,
+			"dropped_sessions": dropped,
+		})
+	}
+}
+
+// entryChanges names the fields in which two entries of the same lecture
+// differ, and whether it moved to another slot or room.
+func entryChanges(from, to models.TimetableEntry) (changes []string, moved bool) {
+	changes = []string{}
+	for _, f := range []struct {
+		name    string
+		changed bool
+		slot    bool
+	}{
+		{"day_of_week", from.DayOfWeek != to.DayOfWeek, true},
+		{"start_time", from.StartTime != to.StartTime, true},
+		{"end_time", from.EndTime != to.EndTime, true},
+		{"room_id", from.RoomID != to.RoomID, true},
+		{"subject_id", from.SubjectID != to.SubjectID, false},
+		{"faculty_id", from.FacultyID != to.FacultyID, false},
+		{"batch_id", from.BatchID != to.BatchID, false},
+		{"batches", !sameBatches(
+			models.Lecture{BatchID: from.BatchID, Batches: from.Batches},
+			models.Lecture{BatchID: to.BatchID, Batches: to.Batches},
+		), false},
+	} {
+		if f.changed {
+			changes = append(changes, f.name)
+			moved = moved || f.slot
+		}
+	}
+	return changes, moved
+}
+
+// DiffTimetableVersions compares two timetable versions by the lectures their
+// entries stand for. Entries only in 'to' were added and entries only in
+// 'from' removed; a lecture in both moved if its day, times or room differ,
+// and changed if only its subject, faculty or batches do.
+func DiffTimetableVersions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Query("from") == "" || c.Query("to") == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Both 'from' and 'to' version IDs are required."})
+			return
+		}
+
+		var from, to models.TimetableVersion
+		if err := loadVersion(db, c.Query("from"), &from); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "'from' version not found"})
+			return
+		}
+		if err := loadVersion(db, c.Query("to"), &to); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "'to' version not found"})
+			return
+		}
+
+		before := make(map[uint]models.TimetableEntry, len(from.Entries))
+		for _, e := range from.Entries {
+			if e.LectureID != nil {
+				before[*e.LectureID] = e
+			}
+		}
+
+		added := []models.TimetableEntry{}
+		moved, changed := []gin.H{}, []gin.H{}
+		unchanged := 0
+		for _, e := range to.Entries {
+			if e.LectureID == nil {
+				added = append(added, e)
+				continue
+			}
+			old, ok := before[*e.LectureID]
+			if !ok {
+				added = append(added, e)
+				continue
+			}
+			delete(before, *e.LectureID)
+
+			changes, isMove := entryChanges(old, e)
+			item := gin.H{"lecture_id": *e.LectureID, "changes": changes, "from": old, "to": e}
+			switch {
+			case isMove:
+				moved = append(moved, item)
+			case len(changes) > 0:
+				changed = append(changed, item)
+			default:
+				unchanged++
+			}
+		}
+		removed := []models.TimetableEntry{}
+		for _, e := range from.Entries {
+			if e.LectureID == nil {
+				removed = append(removed, e)
+			} else if _, ok := before[*e.LectureID]; ok {
+				removed = append(removed, e)
+			}
+		}
+
+		summary := func(v models.TimetableVersion) gin.H {
+			return gin.H{"id": v.ID, "batch_id": v.BatchID, "semester": v.Semester, "number": v.Number, "status": v.Status}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"from":      summary(from),
+			"to":        summary(to),
+			"added":     added,
+			"removed":   removed,
+			"moved":     moved,
+			"changed":   changed,
+			"unchanged": unchanged,
+		})
+	}
+}
+
+// timetableRef names the timetable of a batch for a semester.
+type timetableRef struct {
+	BatchID  uint `json:"batch_id" binding:"required"`
+	Semester uint `json:"semester" binding:"required"`
+}
+
+// cloneResult is a source lecture that was copied with warnings or that
+// couldn't be copied, and why.
+type cloneResult struct {
+	LectureID uint           `json:"lecture_id"`
+	Reason    string         `json:"reason,omitempty"`
+	Issues    []lectureIssue `json:"issues,omitempty"`
+}
+
+// remap returns what id is mapped to, or id itself.
+func remap(mapping map[uint]uint, id uint) uint {
+	if to, ok := mapping[id]; ok {
+		return to
+	}
+	return id
+}
+
+// cloneBatchMapping maps the source batch to the target and each of its
+// groups to the target's group of the same section suffix (A1 of A to B1 of
+// B), then applies the explicit mapping on top.
+func cloneBatchMapping(source, target models.Batch, explicit map[uint]uint) map[uint]uint {
+	mapping := map[uint]uint{source.ID: target.ID}
+	for _, g := range source.Groups {
+		suffix := strings.TrimPrefix(g.Section, source.Section)
+		for _, tg := range target.Groups {
+			if tg.Section == target.Section+suffix {
+				mapping[g.ID] = tg.ID
+				break
+			}
+		}
+	}
+	for from, to := range explicit {
+		mapping[from] = to
+	}
+	return mapping
+}
+
+// CloneTimetable copies the lectures of a batch's timetable for a semester,
+// its groups' included, to another batch and semester. Subjects, faculty,
+// rooms and batches may be remapped. Each copy is checked against the
+// scheduling rules on the target's timetable as it fills up; copies breaking
+// a blocking rule are skipped and reported. With dry_run nothing is written.
+func CloneTimetable(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Source  timetableRef `json:"source"`
+			Target  timetableRef `json:"target"`
+			Mapping struct {
+				Subjects map[uint]uint `json:"subjects"`
+				Faculty  map[uint]uint `json:"faculty"`
+				Rooms    map[uint]uint `json:"rooms"`
+				Batches  map[uint]uint `json:"batches"`
+			} `json:"mapping"`
+			DryRun bool `json:"dry_run"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if input.Source == input.Target {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "source and target must differ"})
+			return
+		}
+
+		var source, target models.Batch
+		if err := db.Preload("Groups").First(&source, input.Source.BatchID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "source batch not found"})
+			return
+		}
+		if err := db.Preload("Groups").First(&target, input.Target.BatchID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "target batch not found"})
+			return
+		}
+		if source.ParentID != nil || target.ParentID != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "groups are part of their parent batch's timetable"})
+			return
+		}
+
+		scope, err := timetableBatchIDs(db, source.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		lectures, err := liveLectures(db, scope, input.Source.Semester)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch lectures"})
+			return
+		}
+
+		subjectIDs := []uint{}
+		for _, l := range lectures {
+			subjectIDs = append(subjectIDs, remap(input.Mapping.Subjects, l.SubjectID))
+		}
+		var subjects []models.Subject
+		if err := db.Where("id IN ?", subjectIDs).Find(&subjects).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch subjects"})
+			return
+		}
+		subjectByID := make(map[uint]models.Subject, len(subjects))
+		for _, s := range subjects {
+			subjectByID[s.ID] = s
+		}
+
+		batches := cloneBatchMapping(source, target, input.Mapping.Batches)
+		copied := []models.Lecture{}
+		skipped, warnings := []cloneResult{}, []cloneResult{}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			for _, l := range lectures {
+				clone := models.Lecture{
+					DayOfWeek: l.DayOfWeek,
+					StartTime: l.StartTime,
+					EndTime:   l.EndTime,
+					SubjectID: remap(input.Mapping.Subjects, l.SubjectID),
+					FacultyID: remap(input.Mapping.Faculty, l.FacultyID),
+					Semester:  input.Target.Semester,
+					RoomID:    remap(input.Mapping.Rooms, l.RoomID),
+				}
+
+				reason := ""
+				if subject, ok := subjectByID[clone.SubjectID]; !ok {
+					reason = fmt.Sprintf("subject %d not found", clone.SubjectID)
+				} else if subject.CourseID != target.CourseID {
+					reason = fmt.Sprintf("subject %s belongs to another course than the target batch, map it in mapping.subjects", subject.Code)
+				} else if subject.Semester != 0 && subject.Semester != input.Target.Semester {
+					reason = fmt.Sprintf("subject %s is taught in semester %d, map it in mapping.subjects", subject.Code, subject.Semester)
+				}
+				for i, id := range lectureBatchIDs(l) {
+					to, ok := batches[id]
+					if !ok && reason == "" {
+						reason = fmt.Sprintf("batch %d has no counterpart in the target, map it in mapping.batches", id)
+					}
+					if i == 0 {
+						clone.BatchID = to
+					} else {
+						clone.Batches = append(clone.Batches, models.Batch{ID: to})
+					}
+				}
+				if reason != "" {
+					skipped = append(skipped, cloneResult{LectureID: l.ID, Reason: reason})
+					continue
+				}
+
+				blocking, w, err := validateLecture(tx, &clone)
+				if err != nil {
+					return err
+				}
+				if len(blocking) > 0 {
+					skipped = append(skipped, cloneResult{LectureID: l.ID, Reason: "violates scheduling rules", Issues: blocking})
+					continue
+				}
+				if err := writeLecture(tx, &clone); err != nil {
+					return err
+				}
+				if len(w) > 0 {
+					warnings = append(warnings, cloneResult{LectureID: l.ID, Issues: w})
+				}
+				copied = append(copied, clone)
+			}
+			if input.DryRun {
+				return errDryRun
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errDryRun) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		status := http.StatusCreated
+		if input.DryRun {
+			// The copies were rolled back.
+			for i := range copied {
+				copied[i].ID = 0
+			}
+			status = http.StatusOK
+		}
+		c.JSON(status, gin.H{
+			"data":     copied,
+			"copied":   len(copied),
+			"skipped":  skipped,
+			"warnings": warnings,
+			"dry_run":  input.DryRun,
+		})
+	}
+}
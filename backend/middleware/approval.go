@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+	"tms-server/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChangeApproval turns admins away from direct timetable changes while
+// config.ChangeApprovalRequired; they have to go through change requests.
+// Superadmins pass, as do reviewers on the routes open to them, which is
+// publishing a draft: lecture writes and cloning stay in the admin group.
+func ChangeApproval() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		if !config.ChangeApprovalRequired() || role == "reviewer" || role == "superadmin" {
+			c.Next()
+			return
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "timetable changes need an approved change request"})
+		c.Abort()
+	}
+}
@@ -7,6 +7,14 @@ import (
 
 // INFO: for UP and DOWN migration: github.com/golang-migrate/migrate/v4
 func Migrate() error {
+	if err := prepareTimeColumns(); err != nil {
+		return err
+	}
+
+	// Batches predating the rollover start at the semester of their latest
+	// lectures rather than the column default.
+	backfill := !config.DB.Migrator().HasColumn(&models.Batch{}, "current_semester")
+
 	err := config.DB.AutoMigrate(
 		&models.User{},
 		&models.Faculty{},
@@ -16,6 +24,53 @@ func Migrate() error {
 		&models.Room{},
 		&models.Lecture{},
 		&models.Session{},
+		&models.Substitution{},
+		&models.Leave{},
+		&models.FacultyAvailability{},
+		&models.DesignationLimit{},
+		&models.TimeSlot{},
+		&models.ElectiveGroup{},
+		&models.ElectiveChoice{},
+		&models.SyllabusUnit{},
+		&models.SyllabusTopic{},
+		&models.Student{},
+		&models.Attendance{},
+		&models.TimetableVersion{},
+		&models.TimetableEntry{},
+		&models.ChangeRequest{},
+		&models.ClosedTerm{},
 	)
-	return err
+	if err != nil || !backfill {
+		return err
+	}
+	return backfillCurrentSemester()
+}
+
+// backfillCurrentSemester sets each batch, its groups included, to the latest
+// semester it has lectures for.
+func backfillCurrentSemester() error {
+	return config.DB.Exec(`UPDATE batches SET current_semester = latest.semester
+		FROM (
+			SELECT COALESCE(batches.parent_id, batches.id) AS batch_id, MAX(lectures.semester) AS semester
+			FROM lectures JOIN batches ON batches.id = lectures.batch_id
+			WHERE lectures.semester > 0
+			GROUP BY 1
+		) latest
+		WHERE latest.batch_id = COALESCE(batches.parent_id, batches.id)`).Error
+}
+
+// prepareTimeColumns lets AutoMigrate turn the session times, once text
+// columns defaulting to an empty string, into nullable time columns.
+func prepareTimeColumns() error {
+	db := config.DB
+	if !db.Migrator().HasColumn(&models.Session{}, "start_time") {
+		return nil
+	}
+
+	for _, column := range []string{"start_time", "end_time"} {
+		if err := db.Exec("ALTER TABLE sessions ALTER COLUMN " + column + " DROP DEFAULT").Error; err != nil {
+			return err
+		}
+	}
+	return db.Exec("UPDATE sessions SET start_time = NULL, end_time = NULL WHERE start_time::text = ''").Error
 }
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Attendance is a student's attendance in one session.
+type Attendance struct {
+	ID         uint   `gorm:"primaryKey"`
+	SessionID  uint   `gorm:"not null;uniqueIndex:idx_attendance"`
+	StudentID  uint   `gorm:"not null;uniqueIndex:idx_attendance;index"`
+	Status     string `gorm:"not null"` // present, absent, late or excused
+	Remarks    string
+	MarkedByID *uint `gorm:"default:null"`
+	UpdatedAt  time.Time
+
+	Session  Session `gorm:"foreignKey:SessionID"`
+	Student  Student `gorm:"foreignKey:StudentID"`
+	MarkedBy *User   `gorm:"foreignKey:MarkedByID"`
+}
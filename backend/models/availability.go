@@ -0,0 +1,12 @@
+package models
+
+// FacultyAvailability is a weekly window in which a faculty is available,
+// prefers to teach, or must not be scheduled.
+type FacultyAvailability struct {
+	ID        uint      `gorm:"primaryKey"`
+	FacultyID uint      `gorm:"not null;index"`
+	DayOfWeek string    `gorm:"not null"` // e.g., Tuesday
+	StartTime TimeOfDay `gorm:"not null"` // JSON format: "09:00"
+	EndTime   TimeOfDay `gorm:"not null"` // JSON format: "12:00"
+	Kind      string    `gorm:"not null"` // available, preferred, blocked
+}
@@ -3,8 +3,17 @@ package models
 type Batch struct {
 	ID       uint   `gorm:"primaryKey"`
 	Year     int    `gorm:"not null"` // e.g., 2023
-	Section  string `gorm:"not null"` // e.g., A, B
+	Section  string `gorm:"not null"` // e.g., A, B, or A1 for a group
+	Strength int    // number of students
 	CourseID uint   `gorm:"not null"`
 	Course   Course
 	Lectures []Lecture
+
+	// Where the batch stands in its course, advanced by the semester rollover
+	CurrentSemester uint `gorm:"default:1;not null"`
+	Graduated       bool `gorm:"default:false;not null"` // past its course's final semester
+
+	// Sub-groups (e.g. A1/A2 for labs) point at the batch they split
+	ParentID *uint   `gorm:"default:null;index"`
+	Groups   []Batch `gorm:"foreignKey:ParentID"`
 }
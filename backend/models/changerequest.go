@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// ChangeRequest proposes adding, moving or removing a lecture. It reaches the
+// lectures only once a reviewer approves it; rejected and approved requests
+// stay as the history of the batch's timetable.
+type ChangeRequest struct {
+	ID        uint   `gorm:"primaryKey"`
+	Kind      string `gorm:"not null"`                   // add, move, remove
+	Status    string `gorm:"default:'pending';not null"` // pending, approved, rejected
+	BatchID   uint   `gorm:"not null;index"`             // batch whose timetable changes
+	LectureID *uint  `gorm:"default:null;index"`         // lecture moved or removed, or added once approved
+	Reason    string
+
+	// The lecture as proposed by an addition or move, or as it was when its
+	// removal was requested
+	DayOfWeek string
+	StartTime TimeOfDay
+	EndTime   TimeOfDay
+	SubjectID uint
+	FacultyID uint
+	RoomID    uint
+	Semester  uint
+	Batches   []Batch `gorm:"many2many:change_request_batches;"`
+
+	// Where a moved lecture was before the move was applied
+	PreviousDayOfWeek string
+	PreviousStartTime *TimeOfDay `gorm:"default:null"`
+	PreviousEndTime   *TimeOfDay `gorm:"default:null"`
+	PreviousRoomID    *uint      `gorm:"default:null"`
+
+	RequestedByID uint `gorm:"not null"`
+	CreatedAt     time.Time
+	ReviewedByID  *uint      `gorm:"default:null"`
+	ReviewedAt    *time.Time `gorm:"default:null"`
+	ReviewComment string
+
+	Batch       Batch `gorm:"foreignKey:BatchID"`
+	RequestedBy User  `gorm:"foreignKey:RequestedByID"`
+	ReviewedBy  *User `gorm:"foreignKey:ReviewedByID"`
+}
+
+// ChangeRequestBatch is the join row between a ChangeRequest for a combined
+// lecture and one of its further Batches.
+type ChangeRequestBatch struct {
+	ChangeRequestID uint `gorm:"primaryKey"`
+	BatchID         uint `gorm:"primaryKey"`
+}
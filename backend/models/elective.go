@@ -0,0 +1,22 @@
+package models
+
+// ElectiveGroup is a set of elective subjects of a course and semester taught
+// in parallel; each student takes one of them.
+type ElectiveGroup struct {
+	ID       uint   `gorm:"primaryKey"`
+	Name     string `gorm:"not null"` // e.g., Elective II
+	CourseID uint   `gorm:"not null"`
+	Semester uint   `gorm:"not null"`
+	Course   Course
+	Subjects []Subject `gorm:"foreignKey:ElectiveGroupID"`
+}
+
+// ElectiveChoice records how many students of a batch chose an elective.
+type ElectiveChoice struct {
+	ID        uint `gorm:"primaryKey"`
+	BatchID   uint `gorm:"not null;uniqueIndex:idx_elective_choice"`
+	SubjectID uint `gorm:"not null;uniqueIndex:idx_elective_choice"`
+	Students  int  `gorm:"not null"`
+	Batch     Batch
+	Subject   Subject
+}
@@ -1,9 +1,24 @@
 package models
 
 type Faculty struct {
-	ID       uint      `gorm:"primaryKey"`
-	Name     string    `gorm:"not null"`
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"not null"`
+	Designation string // e.g., Professor, Assistant Professor
+
+	// Workload limits, overriding the ones of the faculty's designation
+	MaxWeeklyHours        *float64 `gorm:"default:null"`
+	MaxConsecutivePeriods *int     `gorm:"default:null"`
+
 	UserID   *uint     `gorm:"default:null"`
 	User     User      `gorm:"foreignKey:UserID"`
 	Subjects []Subject `gorm:"many2many:faculty_subjects;"`
 }
+
+// DesignationLimit holds the default workload limits for every faculty of a
+// designation.
+type DesignationLimit struct {
+	ID                    uint   `gorm:"primaryKey"`
+	Designation           string `gorm:"uniqueIndex;not null"`
+	MaxWeeklyHours        float64
+	MaxConsecutivePeriods int
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+type Leave struct {
+	ID           uint      `gorm:"primaryKey"`
+	FacultyID    uint      `gorm:"not null;index"`
+	StartDate    time.Time `gorm:"type:date;not null"`
+	EndDate      time.Time `gorm:"type:date;not null"`
+	Type         string    `gorm:"not null"`                   // casual, medical, duty, other
+	Status       string    `gorm:"default:'pending';not null"` // pending, approved, rejected
+	Reason       string
+	ReviewedByID *uint `gorm:"default:null"`
+	CreatedAt    time.Time
+
+	Faculty    Faculty `gorm:"foreignKey:FacultyID"`
+	ReviewedBy *User   `gorm:"foreignKey:ReviewedByID"`
+}
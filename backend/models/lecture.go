@@ -1,10 +1,11 @@
 package models
 
 type Lecture struct {
-	ID        uint   `gorm:"primaryKey"`
-	DayOfWeek string `gorm:"not null"` // e.g., Monday
-	StartTime string `gorm:"not null"` // Format: "09:00"
-	EndTime   string `gorm:"not null"` // Format: "10:00"
+	ID        uint      `gorm:"primaryKey"`
+	DayOfWeek string    `gorm:"not null"`           // e.g., Monday
+	StartTime TimeOfDay `gorm:"not null"`           // JSON format: "09:00"
+	EndTime   TimeOfDay `gorm:"not null"`           // JSON format: "10:00"
+	Periods   uint      `gorm:"default:1;not null"` // periods of the grid spanned, e.g. 2 for a lab block
 
 	SubjectID uint
 	FacultyID uint
@@ -16,4 +17,17 @@ type Lecture struct {
 	Faculty Faculty
 	Batch   Batch
 	Room    Room
+
+	// Further batches or groups attending a combined lecture
+	Batches []Batch `gorm:"many2many:lecture_batches;"`
+
+	// Archived lectures are off the timetable but keep their sessions as history
+	Archived bool `gorm:"default:false;not null"`
+}
+
+// LectureBatch is the join row between a combined Lecture and one of its
+// further Batches.
+type LectureBatch struct {
+	LectureID uint `gorm:"primaryKey"`
+	BatchID   uint `gorm:"primaryKey"`
 }
@@ -4,4 +4,6 @@ type Room struct {
 	ID       uint   `gorm:"primaryKey"`
 	Name     string `gorm:"uniqueIndex;not null"`
 	Capacity int
+	Type     string `gorm:"default:'classroom';not null"` // classroom, lab, seminar_hall
+	Features TagSet // e.g., projector, computers
 }
@@ -6,7 +6,45 @@ type Session struct {
 	ID        uint      `gorm:"primaryKey"`
 	LectureID uint      `gorm:"not null"`
 	Date      time.Time `gorm:"type:date;not null"` // Stores only date (YYYY-MM-DD)
-	Status    string
+	Status    SessionStatus
 
-	Lecture Lecture `gorm:"foreignKey:LectureID"`
+	// Why a cancelled session was cancelled, and who last changed the status
+	CancelReason      CancelReason `gorm:"default:null"`
+	StatusNote        string
+	StatusChangedByID *uint      `gorm:"default:null"`
+	StatusChangedAt   *time.Time `gorm:"default:null"`
+
+	// One-off sessions (e.g. make-up classes) carry their own slot and room;
+	// regular sessions leave these empty and follow their Lecture.
+	StartTime   *TimeOfDay `gorm:"default:null"` // JSON format: "09:00"
+	EndTime     *TimeOfDay `gorm:"default:null"` // JSON format: "10:00"
+	RoomID      *uint      `gorm:"default:null"`
+	MakeupForID *uint      `gorm:"default:null;index"` // cancelled session this one makes up for
+
+	// What was taught, logged when the session is marked held
+	Notes  string
+	Topics []SyllabusTopic `gorm:"many2many:session_topics;"`
+
+	// Sessions of a term closed by the semester rollover, kept as history
+	Archived bool `gorm:"default:false;not null"`
+
+	Lecture      Lecture       `gorm:"foreignKey:LectureID"`
+	Room         *Room         `gorm:"foreignKey:RoomID"`
+	MakeupFor    *Session      `gorm:"foreignKey:MakeupForID"`
+	Substitution *Substitution `gorm:"foreignKey:SessionID"`
+
+	StatusChangedBy *User `gorm:"foreignKey:StatusChangedByID"`
+}
+
+// Slot returns when and where the session takes place, falling back to its
+// Lecture for regular sessions. Lecture must be loaded.
+func (s Session) Slot() (start, end TimeOfDay, roomID uint) {
+	start, end, roomID = s.Lecture.StartTime, s.Lecture.EndTime, s.Lecture.RoomID
+	if s.StartTime != nil && s.EndTime != nil {
+		start, end = *s.StartTime, *s.EndTime
+	}
+	if s.RoomID != nil {
+		roomID = *s.RoomID
+	}
+	return start, end, roomID
 }
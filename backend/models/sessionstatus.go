@@ -0,0 +1,72 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// SessionStatus is where a session stands. A session without a status is
+// scheduled and still open.
+type SessionStatus string
+
+const (
+	SessionScheduled SessionStatus = ""
+	SessionHeld      SessionStatus = "held"
+	SessionCancelled SessionStatus = "cancelled"
+
+	// Flags for open sessions of a faculty on approved leave
+	SessionNeedsSubstitution SessionStatus = "needs_substitution"
+	SessionNeedsCancellation SessionStatus = "needs_cancellation"
+)
+
+var SessionStatuses = []SessionStatus{
+	SessionScheduled,
+	SessionHeld,
+	SessionCancelled,
+	SessionNeedsSubstitution,
+	SessionNeedsCancellation,
+}
+
+func (s *SessionStatus) UnmarshalJSON(data []byte) error {
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if !slices.Contains(SessionStatuses, SessionStatus(v)) {
+		return fmt.Errorf("invalid session status %q", v)
+	}
+	*s = SessionStatus(v)
+	return nil
+}
+
+// CancelReason says why a session was cancelled.
+type CancelReason string
+
+const (
+	CancelFacultyLeave    CancelReason = "faculty_leave"
+	CancelHoliday         CancelReason = "holiday"
+	CancelRoomUnavailable CancelReason = "room_unavailable"
+	CancelStrike          CancelReason = "strike"
+	CancelOther           CancelReason = "other"
+)
+
+var CancelReasons = []CancelReason{
+	CancelFacultyLeave,
+	CancelHoliday,
+	CancelRoomUnavailable,
+	CancelStrike,
+	CancelOther,
+}
+
+func (r *CancelReason) UnmarshalJSON(data []byte) error {
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v != "" && !slices.Contains(CancelReasons, CancelReason(v)) {
+		return fmt.Errorf("invalid cancel reason %q", v)
+	}
+	*r = CancelReason(v)
+	return nil
+}
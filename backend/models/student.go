@@ -0,0 +1,16 @@
+package models
+
+type Student struct {
+	ID         uint   `gorm:"primaryKey"`
+	RollNumber string `gorm:"uniqueIndex;not null"` // e.g., IT-2K21-35
+	Name       string `gorm:"not null"`
+	Email      string
+	BatchID    uint  `gorm:"not null;index"`
+	GroupID    *uint `gorm:"default:null;index"` // sub-group of the batch, e.g. A1 for labs
+	UserID     *uint `gorm:"default:null"`
+
+	Batch     Batch     `gorm:"foreignKey:BatchID"`
+	Group     *Batch    `gorm:"foreignKey:GroupID"`
+	User      *User     `gorm:"foreignKey:UserID"`
+	Electives []Subject `gorm:"many2many:student_electives;"`
+}
@@ -7,4 +7,18 @@ type Subject struct {
 	CourseID  uint   `gorm:"not null"`
 	Course    Course
 	Faculties []Faculty `gorm:"many2many:faculty_subjects;"`
+
+	// Teaching scheme: weekly Lecture-Tutorial-Practical hours and credits
+	Semester       uint
+	LectureHours   uint
+	TutorialHours  uint
+	PracticalHours uint
+	Credits        float64
+
+	// Room its lectures need, e.g. a lab with computers for practicals
+	RequiredRoomType string
+	RequiredFeatures TagSet
+
+	// Elective subjects of a group share a slot, each student attends one
+	ElectiveGroupID *uint `gorm:"default:null;index"`
 }
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Substitution records that a Session was taken by someone other than the
+// faculty assigned to its Lecture.
+type Substitution struct {
+	ID                  uint `gorm:"primaryKey"`
+	SessionID           uint `gorm:"uniqueIndex;not null"`
+	OriginalFacultyID   uint `gorm:"not null"`
+	SubstituteFacultyID uint `gorm:"not null;index"`
+	Reason              string
+	ApprovedByID        *uint `gorm:"default:null"`
+	CreatedAt           time.Time
+
+	OriginalFaculty   Faculty `gorm:"foreignKey:OriginalFacultyID"`
+	SubstituteFaculty Faculty `gorm:"foreignKey:SubstituteFacultyID"`
+	ApprovedBy        *User   `gorm:"foreignKey:ApprovedByID"`
+}
@@ -0,0 +1,19 @@
+package models
+
+// SyllabusUnit is a numbered unit of a subject's syllabus.
+type SyllabusUnit struct {
+	ID        uint   `gorm:"primaryKey"`
+	SubjectID uint   `gorm:"not null;index"`
+	Number    uint   `gorm:"not null"` // e.g., 1 for Unit I
+	Title     string `gorm:"not null"`
+	Subject   Subject
+	Topics    []SyllabusTopic `gorm:"foreignKey:UnitID"`
+}
+
+// SyllabusTopic is a topic of a syllabus unit, covered in one or more sessions.
+type SyllabusTopic struct {
+	ID     uint   `gorm:"primaryKey"`
+	UnitID uint   `gorm:"not null;index"`
+	Number uint   `gorm:"not null"` // order within the unit
+	Title  string `gorm:"not null"`
+}
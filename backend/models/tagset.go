@@ -0,0 +1,58 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// TagSet is a set of lowercase tags stored as comma-separated text,
+// e.g. "projector,smart_board".
+type TagSet []string
+
+// NewTagSet normalizes tags: trimmed, lowercased, sorted and de-duplicated.
+func NewTagSet(tags ...string) TagSet {
+	set := TagSet{}
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" && !slices.Contains(set, t) {
+			set = append(set, t)
+		}
+	}
+	slices.Sort(set)
+	return set
+}
+
+// Missing returns the tags of required that are not in s.
+func (s TagSet) Missing(required TagSet) TagSet {
+	missing := TagSet{}
+	for _, t := range NewTagSet(required...) {
+		if !slices.Contains(NewTagSet(s...), t) {
+			missing = append(missing, t)
+		}
+	}
+	return missing
+}
+
+func (s *TagSet) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*s = TagSet{}
+	case string:
+		*s = NewTagSet(strings.Split(v, ",")...)
+	case []byte:
+		*s = NewTagSet(strings.Split(string(v), ",")...)
+	default:
+		return fmt.Errorf("cannot scan %T into TagSet", value)
+	}
+	return nil
+}
+
+func (s TagSet) Value() (driver.Value, error) {
+	return strings.Join(NewTagSet(s...), ","), nil
+}
+
+func (TagSet) GormDataType() string {
+	return "text"
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// ClosedTerm records a term of a course closed by the semester rollover, so
+// that the same term can't be closed twice.
+type ClosedTerm struct {
+	ID         uint   `gorm:"primaryKey"`
+	CourseID   uint   `gorm:"not null;uniqueIndex:idx_closed_term"`
+	Term       string `gorm:"not null;uniqueIndex:idx_closed_term"` // e.g. 2026-odd
+	ClosedByID *uint  `gorm:"default:null"`
+	ClosedAt   time.Time
+
+	Course   Course `gorm:"foreignKey:CourseID"`
+	ClosedBy *User  `gorm:"foreignKey:ClosedByID"`
+}
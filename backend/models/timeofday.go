@@ -0,0 +1,76 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// TimeOfDay is a wall-clock time stored as a Postgres time column and
+// written as "HH:MM" in JSON. Its value is the number of minutes since
+// midnight, so times compare and subtract naturally.
+type TimeOfDay int
+
+// ParseTimeOfDay parses "HH:MM" or "HH:MM:SS" (seconds are dropped).
+func ParseTimeOfDay(s string) (TimeOfDay, error) {
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return TimeOfDay(t.Hour()*60 + t.Minute()), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time of day %q, use HH:MM", s)
+}
+
+func (t TimeOfDay) String() string {
+	return fmt.Sprintf("%02d:%02d", int(t)/60, int(t)%60)
+}
+
+func (t TimeOfDay) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *TimeOfDay) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseTimeOfDay(s)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+func (t *TimeOfDay) Scan(value any) error {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	case time.Time:
+		*t = TimeOfDay(v.Hour()*60 + v.Minute())
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into TimeOfDay", value)
+	}
+	if len(s) > 8 {
+		s = s[:8] // drop fractional seconds
+	}
+	parsed, err := ParseTimeOfDay(s)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+func (t TimeOfDay) Value() (driver.Value, error) {
+	return t.String() + ":00", nil
+}
+
+func (TimeOfDay) GormDataType() string {
+	return "time"
+}
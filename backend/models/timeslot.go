@@ -0,0 +1,15 @@
+package models
+
+// TimeSlot is one period of the daily grid. Slots without a CourseID apply to
+// the whole institute, a course with slots of its own uses only those.
+type TimeSlot struct {
+	ID        uint      `gorm:"primaryKey"`
+	Period    uint      `gorm:"not null"` // e.g., 1 for the first period of the day
+	StartTime TimeOfDay `gorm:"not null"`
+	EndTime   TimeOfDay `gorm:"not null"`
+	IsBreak   bool      `gorm:"default:false;not null"`
+	Days      TagSet    // e.g., ["monday", "tuesday"], empty for every day
+	CourseID  *uint     `gorm:"default:null;index"`
+
+	Course *Course `gorm:"foreignKey:CourseID"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+// Timetable version statuses. A batch has at most one draft and one published
+// version per semester; publishing a draft supersedes the published one.
+const (
+	VersionDraft      = "draft"
+	VersionPublished  = "published"
+	VersionSuperseded = "superseded"
+)
+
+// TimetableVersion is a numbered revision of a batch's timetable for a
+// semester, covering the lectures of the batch and its groups. Only drafts
+// are edited; publishing one writes its entries to the live lectures, and
+// published versions stay as read-only history. A draft can't be published
+// once the live lectures changed after it was created.
+type TimetableVersion struct {
+	ID            uint   `gorm:"primaryKey"`
+	BatchID       uint   `gorm:"not null;uniqueIndex:idx_timetable_version"`
+	Semester      uint   `gorm:"not null;uniqueIndex:idx_timetable_version"`
+	Number        uint   `gorm:"not null;uniqueIndex:idx_timetable_version"`
+	Status        string `gorm:"not null;default:'draft'"` // draft, published or superseded
+	Note          string
+	BaseHash      string // fingerprint of the live lectures a draft was copied from
+	CreatedByID   *uint  `gorm:"default:null"`
+	CreatedAt     time.Time
+	PublishedByID *uint      `gorm:"default:null"`
+	PublishedAt   *time.Time `gorm:"default:null"`
+
+	Batch   Batch
+	Entries []TimetableEntry `gorm:"foreignKey:VersionID"`
+}
+
+// TimetableEntry is a lecture as planned in a TimetableVersion. LectureID is
+// the live lecture it was copied from or published as; versions are compared
+// by it.
+type TimetableEntry struct {
+	ID        uint      `gorm:"primaryKey"`
+	VersionID uint      `gorm:"not null;index"`
+	LectureID *uint     `gorm:"default:null"`
+	DayOfWeek string    `gorm:"not null"`
+	StartTime TimeOfDay `gorm:"not null"`
+	EndTime   TimeOfDay `gorm:"not null"`
+	Periods   uint      `gorm:"default:1;not null"`
+
+	SubjectID uint
+	FacultyID uint
+	BatchID   uint
+	RoomID    uint
+
+	Subject Subject
+	Faculty Faculty
+	Batch   Batch
+	Room    Room
+
+	// Further batches or groups attending a combined lecture
+	Batches []Batch `gorm:"many2many:timetable_entry_batches;"`
+}
+
+// TimetableEntryBatch is the join row between a combined TimetableEntry and
+// one of its further Batches.
+type TimetableEntryBatch struct {
+	TimetableEntryID uint `gorm:"primaryKey"`
+	BatchID          uint `gorm:"primaryKey"`
+}
@@ -20,47 +20,95 @@ func RegisterRoutes(r *gin.Engine) {
 	api.GET("/ping", controllers.Ping)
 	api.POST("/login", controllers.Login)
 
-	// Protected routes (faculty+)
 	api.Use(middleware.JWTAuthMiddleware())
 	api.POST("/logout", controllers.Logout)
-	registerFacultyRoutes(api, db)
+
+	// Student self-service routes
+	student := api.Group("/")
+	student.Use(middleware.RoleAuthMiddleware("student"))
+	registerStudentRoutes(student, db)
+
+	// Protected routes (faculty+, and reviewers to read what they review)
+	staff := api.Group("/")
+	staff.Use(middleware.RoleAuthMiddleware("faculty", "admin", "superadmin", "reviewer"))
+	registerFacultyRoutes(staff, db)
 
 	// Admin-only routes
 	admin := api.Group("/")
 	admin.Use(middleware.RoleAuthMiddleware("admin", "superadmin"))
 	registerAdminRoutes(admin, db)
 
+	// Timetable versions, which reviewers read and publish too
+	timetable := api.Group("/")
+	timetable.Use(middleware.RoleAuthMiddleware("admin", "superadmin", "reviewer"))
+	registerTimetableRoutes(timetable, db)
+
+	// Reviewer routes, e.g. the head of department approving timetable changes
+	reviewer := api.Group("/")
+	reviewer.Use(middleware.RoleAuthMiddleware("reviewer", "superadmin"))
+	registerReviewerRoutes(reviewer, db)
+
 	// Superadmin-only routes
 	super := api.Group("/")
 	super.Use(middleware.RoleAuthMiddleware("superadmin"))
 	registerSuperAdminRoutes(super, db)
 }
 
+func registerStudentRoutes(r *gin.RouterGroup, db *gorm.DB) {
+	r.POST("/checkin", controllers.CheckIn(db))
+}
+
 func registerFacultyRoutes(r *gin.RouterGroup, db *gorm.DB) {
 	r.GET("/course", controllers.All[models.Course](db))
 	r.GET("/course/:id", controllers.Get[models.Course](db))
 
 	r.GET("/subject", controllers.All[models.Subject](db))
 	r.GET("/subject/:id", controllers.Get[models.Subject](db))
+	r.GET("/subject/:id/syllabus", controllers.GetSyllabus(db))
 
 	r.GET("/faculty", controllers.All[models.Faculty](db))
 	r.GET("/faculty/:id", controllers.Get[models.Faculty](db))
+	r.GET("/faculty/:id/availability", controllers.GetFacultyAvailability(db))
 
 	r.GET("/room", controllers.All[models.Room](db))
+	r.GET("/room/available", controllers.AvailableRooms(db))
 	r.GET("/room/:id", controllers.Get[models.Room](db))
 
 	r.GET("/batch", controllers.All[models.Batch](db))
 	r.GET("/batch/:id", controllers.Get[models.Batch](db))
 
+	r.GET("/student", controllers.QueryStudents(db))
+	r.GET("/student/:id", controllers.GetStudent(db))
+
+	r.GET("/elective-group", controllers.QueryElectiveGroups(db))
+	r.GET("/elective-group/:id", controllers.Get[models.ElectiveGroup](db))
+	r.GET("/elective-group/:id/choices", controllers.GetElectiveChoices(db))
+
+	r.GET("/timeslot", controllers.QueryTimeSlots(db))
+	r.GET("/timeslot/:id", controllers.Get[models.TimeSlot](db))
+
 	r.GET("/lecture", controllers.QueryLectures(db)) // for backwards compatibility, use /query
 	r.GET("/lecture/query", controllers.QueryLectures(db))
 	r.GET("/lecture/:id", controllers.Get[models.Lecture](db))
 
 	r.GET("/session", controllers.All[models.Session](db))
 	r.GET("/session/:id", controllers.Get[models.Session](db))
+	r.GET("/session/:id/substitutes", controllers.SuggestSubstitutes(db))
+	r.PUT("/session/:id/held", controllers.MarkSessionHeld(db))
+	r.GET("/session/:id/attendance", controllers.GetSessionAttendance(db))
+	r.PUT("/session/:id/attendance", controllers.MarkAttendance(db))
+	r.GET("/session/:id/checkin", controllers.GetCheckinToken(db))
+	r.PUT("/session/:id/status", controllers.SetSessionStatus(db))
+
+	r.GET("/leave", controllers.QueryLeaves(db))
+	r.GET("/leave/:id", controllers.GetLeave(db))
+	r.POST("/leave", controllers.RequestLeave(db))
 
 	r.GET("/calendar", controllers.GetCalendarSummaryByMonth)
 	r.GET("/calendar/day", controllers.GetLectureDetailsByDate)
+
+	r.GET("/change-request", controllers.QueryChangeRequests(db))
+	r.GET("/change-request/:id", controllers.GetChangeRequest(db))
 }
 
 func registerAdminRoutes(r *gin.RouterGroup, db *gorm.DB) {
@@ -74,10 +122,25 @@ func registerAdminRoutes(r *gin.RouterGroup, db *gorm.DB) {
 	r.PUT("/subject/:id", controllers.Update[models.Subject](db))
 	r.DELETE("/subject/:id", controllers.Delete[models.Subject](db))
 
+	// Syllabus
+	r.POST("/syllabus-unit", controllers.Create[models.SyllabusUnit](db))
+	r.PUT("/syllabus-unit/:id", controllers.Update[models.SyllabusUnit](db))
+	r.DELETE("/syllabus-unit/:id", controllers.Delete[models.SyllabusUnit](db))
+	r.POST("/syllabus-topic", controllers.Create[models.SyllabusTopic](db))
+	r.PUT("/syllabus-topic/:id", controllers.Update[models.SyllabusTopic](db))
+	r.DELETE("/syllabus-topic/:id", controllers.Delete[models.SyllabusTopic](db))
+
 	// Faculty
 	r.POST("/faculty", controllers.Create[models.Faculty](db))
 	r.PUT("/faculty/:id", controllers.Update[models.Faculty](db))
 	r.DELETE("/faculty/:id", controllers.Delete[models.Faculty](db))
+	r.PUT("/faculty/:id/availability", controllers.SetFacultyAvailability(db))
+
+	// Designation workload limits
+	r.GET("/designation-limit", controllers.All[models.DesignationLimit](db))
+	r.POST("/designation-limit", controllers.Create[models.DesignationLimit](db))
+	r.PUT("/designation-limit/:id", controllers.Update[models.DesignationLimit](db))
+	r.DELETE("/designation-limit/:id", controllers.Delete[models.DesignationLimit](db))
 
 	// Room
 	r.POST("/room", controllers.Create[models.Room](db))
@@ -89,18 +152,84 @@ func registerAdminRoutes(r *gin.RouterGroup, db *gorm.DB) {
 	r.PUT("/batch/:id", controllers.Update[models.Batch](db))
 	r.DELETE("/batch/:id", controllers.Delete[models.Batch](db))
 
-	// Lecture
-	r.POST("/lecture", controllers.Create[models.Lecture](db))
-	r.PUT("/lecture/:id", controllers.Update[models.Lecture](db))
-	r.DELETE("/lecture/:id", controllers.Delete[models.Lecture](db))
+	// Student
+	r.POST("/student", controllers.CreateStudent(db))
+	r.POST("/student/import", controllers.ImportStudents(db))
+	r.PUT("/student/:id", controllers.UpdateStudent(db))
+	r.DELETE("/student/:id", controllers.Delete[models.Student](db))
+	r.PUT("/student/:id/electives", controllers.SetStudentElectives(db))
+
+	// Elective groups
+	r.POST("/elective-group", controllers.Create[models.ElectiveGroup](db))
+	r.PUT("/elective-group/:id", controllers.Update[models.ElectiveGroup](db))
+	r.DELETE("/elective-group/:id", controllers.Delete[models.ElectiveGroup](db))
+	r.PUT("/elective-group/:id/choices", controllers.SetElectiveChoices(db))
+
+	// Time slots
+	r.POST("/timeslot", controllers.Create[models.TimeSlot](db))
+	r.PUT("/timeslot/:id", controllers.Update[models.TimeSlot](db))
+	r.DELETE("/timeslot/:id", controllers.Delete[models.TimeSlot](db))
+
+	// Lecture, written directly only while changes don't need approval
+	r.POST("/lecture", middleware.ChangeApproval(), controllers.CreateLecture(db))
+	r.POST("/lecture/merge", middleware.ChangeApproval(), controllers.MergeLectures(db))
+	r.PUT("/lecture/:id", middleware.ChangeApproval(), controllers.UpdateLecture(db))
+	r.DELETE("/lecture/:id", middleware.ChangeApproval(), controllers.DeleteLecture(db))
+
+	// Change requests
+	r.POST("/change-request", controllers.CreateChangeRequest(db))
+	r.DELETE("/change-request/:id", controllers.DeleteChangeRequest(db))
+
+	// Timetable drafts
+	r.POST("/timetable/versions", controllers.CreateTimetableDraft(db))
+	r.DELETE("/timetable/versions/:id", controllers.DeleteTimetableDraft(db))
+	r.POST("/timetable/versions/:id/entries", controllers.AddTimetableEntry(db))
+	r.PUT("/timetable/entries/:id", controllers.UpdateTimetableEntry(db))
+	r.DELETE("/timetable/entries/:id", controllers.DeleteTimetableEntry(db))
+	r.POST("/timetable/clone", middleware.ChangeApproval(), controllers.CloneTimetable(db))
 
 	// Session
 	r.POST("/session", controllers.Create[models.Session](db))
-	r.PUT("/session/:id", controllers.Update[models.Session](db))
+	r.PUT("/session/:id", controllers.UpdateSession(db))
 	r.DELETE("/session/:id", controllers.Delete[models.Session](db))
+	r.POST("/session/generate", controllers.GenerateSessions(db))
+	r.POST("/session/:id/reschedule", controllers.RescheduleSession(db))
+	r.POST("/session/:id/substitution", controllers.AssignSubstitute(db))
+	r.DELETE("/session/:id/substitution", controllers.RemoveSubstitute(db))
+
+	// Leave
+	r.PUT("/leave/:id/approve", controllers.ReviewLeave(db, "approved"))
+	r.PUT("/leave/:id/reject", controllers.ReviewLeave(db, "rejected"))
+	r.DELETE("/leave/:id", controllers.DeleteLeave(db))
+
+	// Reports
+	r.GET("/reports/substitutions", controllers.GetSubstitutionReport(db))
+	r.GET("/reports/workload", controllers.GetWorkloadReport(db))
+	r.GET("/reports/room-utilization", controllers.GetRoomUtilizationReport(db))
+	r.GET("/reports/coverage", controllers.GetCoverageReport(db))
+	r.GET("/reports/syllabus-progress", controllers.GetSyllabusProgressReport(db))
+	r.GET("/reports/attendance", controllers.GetAttendanceReport(db))
+	r.GET("/reports/attendance/shortage", controllers.GetAttendanceShortageReport(db))
+	r.GET("/reports/cancellations", controllers.GetCancellationReport(db))
+}
+
+func registerTimetableRoutes(r *gin.RouterGroup, db *gorm.DB) {
+	r.GET("/timetable/versions", controllers.QueryTimetableVersions(db))
+	r.GET("/timetable/versions/:id", controllers.GetTimetableVersion(db))
+	r.GET("/timetable/diff", controllers.DiffTimetableVersions(db))
+	// Left to reviewers once changes need approval
+	r.POST("/timetable/versions/:id/publish", middleware.ChangeApproval(), controllers.PublishTimetableVersion(db))
+}
+
+func registerReviewerRoutes(r *gin.RouterGroup, db *gorm.DB) {
+	r.PUT("/change-request/:id/approve", controllers.ReviewChangeRequest(db, "approved"))
+	r.PUT("/change-request/:id/reject", controllers.ReviewChangeRequest(db, "rejected"))
 }
 
 func registerSuperAdminRoutes(r *gin.RouterGroup, db *gorm.DB) {
+	r.GET("/rollover", controllers.All[models.ClosedTerm](db))
+	r.POST("/rollover", controllers.Rollover(db))
+
 	r.GET("/user", controllers.All[models.User](db))
 	r.POST("/user", controllers.Create[models.User](db))
 	r.GET("/user/:id", controllers.Get[models.User](db))
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CheckinTokenTTL is how long a session check-in token is shown before the
+// next one replaces it, set by APP_CHECKIN_TOKEN_TTL (default 30s).
+func CheckinTokenTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("APP_CHECKIN_TOKEN_TTL")); err == nil && ttl >= time.Second {
+		return ttl
+	}
+	return 30 * time.Second
+}
+
+// ErrNoCheckinSecret is returned while no secret to sign check-in tokens is
+// configured, since tokens signed with an empty key could be forged.
+var ErrNoCheckinSecret = errors.New("check-in is not configured: set APP_CHECKIN_SECRET or APP_JWT_SECRET")
+
+// checkinSecret signs check-in tokens: APP_CHECKIN_SECRET, or the JWT secret
+// when that is not set.
+func checkinSecret() ([]byte, error) {
+	if secret := os.Getenv("APP_CHECKIN_SECRET"); secret != "" {
+		return []byte(secret), nil
+	}
+	if secret := os.Getenv("APP_JWT_SECRET"); secret != "" {
+		return []byte(secret), nil
+	}
+	return nil, ErrNoCheckinSecret
+}
+
+func checkinSignature(sessionID uint, window int64) (string, error) {
+	secret, err := checkinSecret()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "checkin:%d:%d", sessionID, window)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16]), nil
+}
+
+// GenerateCheckinToken returns the check-in token of a session for the time
+// window now falls in, and when it expires. Tokens rotate every
+// CheckinTokenTTL.
+func GenerateCheckinToken(sessionID uint, now time.Time) (string, time.Time, error) {
+	ttl := CheckinTokenTTL()
+	window := now.Unix() / int64(ttl.Seconds())
+	expires := time.Unix((window+1)*int64(ttl.Seconds()), 0)
+	signature, err := checkinSignature(sessionID, window)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return fmt.Sprintf("%d.%d.%s", sessionID, window, signature), expires, nil
+}
+
+// ValidateCheckinToken returns the session a check-in token was issued for.
+// Tokens of the current and the previous window are accepted, so one scanned
+// just before rotating still works.
+func ValidateCheckinToken(token string, now time.Time) (uint, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, errors.New("malformed check-in token")
+	}
+	sessionID, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, errors.New("malformed check-in token")
+	}
+	window, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, errors.New("malformed check-in token")
+	}
+
+	signature, err := checkinSignature(uint(sessionID), window)
+	if err != nil {
+		return 0, err
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(signature)) {
+		return 0, errors.New("invalid check-in token")
+	}
+	current := now.Unix() / int64(CheckinTokenTTL().Seconds())
+	if window != current && window != current-1 {
+		return 0, errors.New("check-in token has expired, scan the current code")
+	}
+	return uint(sessionID), nil
+}
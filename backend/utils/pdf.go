@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A4 landscape in points, printed in 9pt Courier so columns line up.
+const (
+	pdfPageWidth   = 842
+	pdfPageHeight  = 595
+	pdfMargin      = 36
+	pdfFontSize    = 9
+	pdfLineHeight  = 11
+	pdfLineChars   = 142 // Courier glyphs are 0.6em wide
+	pdfMaxColWidth = 40
+)
+
+// TablePDF renders a titled table as a plain PDF document, continuing over as
+// many pages as needed with the header repeated on each. It only uses the
+// standard Courier font, so characters outside ASCII are replaced.
+func TablePDF(title string, header []string, rows [][]string) []byte {
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			if i < len(widths) {
+				widths[i] = min(max(widths[i], len(cell)), pdfMaxColWidth)
+			}
+		}
+	}
+	format := func(row []string) string {
+		cells := make([]string, len(widths))
+		for i := range widths {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			if len(cell) > widths[i] {
+				cell = cell[:widths[i]-1] + "~"
+			}
+			cells[i] = fmt.Sprintf("%-*s", widths[i], cell)
+		}
+		line := strings.Join(cells, "  ")
+		if len(line) > pdfLineChars {
+			line = line[:pdfLineChars]
+		}
+		return line
+	}
+
+	rule := strings.Repeat("-", min(len(format(header)), pdfLineChars))
+	perPage := (pdfPageHeight-2*pdfMargin)/pdfLineHeight - 4
+	var pages [][]string
+	for start := 0; start == 0 || start < len(rows); start += perPage {
+		lines := []string{title, "", format(header), rule}
+		for _, row := range rows[start:min(start+perPage, len(rows))] {
+			lines = append(lines, format(row))
+		}
+		pages = append(pages, lines)
+	}
+
+	return writePDF(pages)
+}
+
+// writePDF lays out pages of text lines, top to bottom.
+func writePDF(pages [][]string) []byte {
+	var buf bytes.Buffer
+	offsets := []int{}
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	// Objects 1-3 are the catalog, page tree and font, followed by a page
+	// and its content stream for every page.
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>")
+
+	for i, lines := range pages {
+		var content bytes.Buffer
+		fmt.Fprintf(&content, "BT /F1 %d Tf %d TL %d %d Td\n", pdfFontSize, pdfLineHeight, pdfMargin, pdfPageHeight-pdfMargin)
+		for _, line := range lines {
+			fmt.Fprintf(&content, "(%s) Tj T*\n", pdfEscape(line))
+		}
+		content.WriteString("ET")
+
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 5+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+// pdfEscape makes s safe inside a PDF string literal.
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 126:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -91,8 +91,17 @@ const StatusBadge = ({ status, onClick, disabled = false }) => {
   );
 };
 
+const cancelReasons = [
+  { value: 'faculty_leave', label: 'Faculty on leave' },
+  { value: 'holiday', label: 'Holiday' },
+  { value: 'room_unavailable', label: 'Room unavailable' },
+  { value: 'strike', label: 'Strike' },
+  { value: 'other', label: 'Other' },
+];
+
 const StatusModal = React.memo(({ session, onClose, onUpdate }) => {
   const [selectedStatus, setSelectedStatus] = useState(session.status || 'held');
+  const [reason, setReason] = useState(session.cancel_reason || 'other');
   const statusModalRef = useRef(null);
 
   const statusOptions = [
@@ -149,6 +158,21 @@ const StatusModal = React.memo(({ session, onClose, onUpdate }) => {
           ))}
         </div>
 
+        {selectedStatus === 'cancelled' && (
+          <div className="mb-6">
+            <label className="text-sm font-semibold text-gray-600 mb-1 block">Reason</label>
+            <select
+              value={reason}
+              onChange={(e) => setReason(e.target.value)}
+              className="w-full px-4 py-2 border border-gray-300 rounded-lg focus:outline-none focus:ring-2 focus:ring-blue-500 bg-white"
+            >
+              {cancelReasons.map((r) => (
+                <option key={r.value} value={r.value}>{r.label}</option>
+              ))}
+            </select>
+          </div>
+        )}
+
         <div className="flex justify-end gap-3">
           <button
             onClick={onClose}
@@ -159,7 +183,7 @@ const StatusModal = React.memo(({ session, onClose, onUpdate }) => {
           <button
             onClick={(e) => {
               e.stopPropagation();
-              onUpdate(session.session_id, selectedStatus);
+              onUpdate(session.session_id, selectedStatus, selectedStatus === 'cancelled' ? reason : '');
             }}
             className="px-4 py-2 bg-blue-600 text-white rounded-lg hover:bg-blue-700 transition-colors flex items-center gap-2"
           >
@@ -356,16 +380,16 @@ function MarkAttendance() {
     setFilteredSessions(filtered);
   };
 
-  const markAttendance = async (sessionId, newStatus) => {
+  const markAttendance = async (sessionId, newStatus, reason) => {
     try {
-      const response = await fetch(`${API_BASE_URL}/session/${sessionId}`, {
+      const response = await fetch(`${API_BASE_URL}/session/${sessionId}/status`, {
         method: 'PUT',
         headers: {
           'Content-Type': 'application/json',
           'Accept': 'application/json'
         },
         credentials: 'include',
-        body: JSON.stringify({ status: newStatus })
+        body: JSON.stringify({ status: newStatus, reason })
       });
 
       if (!response.ok) {
@@ -59,8 +59,17 @@ const CalendarDay = React.memo(({ day, classes, isToday, onClick }) => {
   );
 });
 
+const cancelReasons = [
+  { value: 'faculty_leave', label: 'Faculty on leave' },
+  { value: 'holiday', label: 'Holiday' },
+  { value: 'room_unavailable', label: 'Room unavailable' },
+  { value: 'strike', label: 'Strike' },
+  { value: 'other', label: 'Other' },
+];
+
 const StatusModal = React.memo(({ lecture, onClose, onUpdate }) => {
   const [selectedStatus, setSelectedStatus] = useState(lecture.status || 'held');
+  const [reason, setReason] = useState(lecture.cancel_reason || 'other');
   const statusModalRef = useRef(null);
 
   const statusOptions = [
@@ -111,6 +120,21 @@ const StatusModal = React.memo(({ lecture, onClose, onUpdate }) => {
           ))}
         </div>
 
+        {selectedStatus === 'cancelled' && (
+          <div className="mb-6">
+            <label className="text-sm font-semibold text-gray-600 mb-1 block">Reason</label>
+            <select
+              value={reason}
+              onChange={(e) => setReason(e.target.value)}
+              className="w-full px-4 py-2 border border-gray-300 rounded-lg focus:outline-none focus:ring-2 focus:ring-blue-500 bg-white"
+            >
+              {cancelReasons.map((r) => (
+                <option key={r.value} value={r.value}>{r.label}</option>
+              ))}
+            </select>
+          </div>
+        )}
+
         <div className="flex justify-end gap-3">
           <button
             onClick={onClose}
@@ -121,7 +145,7 @@ const StatusModal = React.memo(({ lecture, onClose, onUpdate }) => {
           <button
             onClick={(e) => {
               e.stopPropagation();
-              onUpdate(lecture.lecture_id, lecture.date, selectedStatus);
+              onUpdate(lecture.lecture_id, lecture.date, selectedStatus, selectedStatus === 'cancelled' ? reason : '');
             }}
             className="px-4 py-2 bg-blue-600 text-white rounded-lg hover:bg-blue-700 transition-colors flex items-center gap-2"
           >
@@ -424,11 +448,11 @@ function ViewTimeTable() {
     }
   };
 
-  const updateLectureStatus = async (lectureId, date, newStatus) => {
+  const updateLectureStatus = async (lectureId, date, newStatus, reason) => {
     setStatusChangeLoading(true);
     try {
       const sessionId = selectedLecture.session_id;
-      const response = await fetch(`${API_BASE_URL}/session/${sessionId}`, {
+      const response = await fetch(`${API_BASE_URL}/session/${sessionId}/status`, {
         method: 'PUT',
         headers: {
           'Content-Type': 'application/json',
@@ -436,7 +460,8 @@ function ViewTimeTable() {
         },
         credentials: 'include',
         body: JSON.stringify({
-          status: newStatus
+          status: newStatus,
+          reason
         })
       });
 